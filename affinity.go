@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"sejm_generator/sejm"
+)
+
+// affinityCell is the size of a cell of the heatmap, in pixels.
+const affinityCell = 96
+
+// deckAffinity measures the affinity of the groups over the legislation of
+// the decks, copies as many times as they are printed.
+func deckAffinity(cards []DeckCard, copies int) *sejm.Affinity {
+	affinity := game.NewAffinity()
+	for _, card := range cards {
+		n := card.Copies
+		if n == 0 {
+			n = copies
+		}
+		affinity.Add(card.Card, n)
+	}
+	return affinity
+}
+
+// writeAffinityCSV writes a row for every pair of groups, with how often
+// they support and oppose cards together and against each other, and how
+// alike they vote.
+func writeAffinityCSV(w io.Writer, affinity *sejm.Affinity) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"group", "other", "co_support", "co_opposition", "conflict", "similarity"})
+	for i, group := range game.Groups {
+		for j, other := range game.Groups {
+			out.Write([]string{group.ID, other.ID,
+				strconv.Itoa(affinity.CoSupport[i][j]), strconv.Itoa(affinity.CoOpposition[i][j]), strconv.Itoa(affinity.Conflict[i][j]),
+				strconv.FormatFloat(affinity.Similarity(i, j), 'f', 3, 64)})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// exportAffinity writes the matrix of the decks as CSV and its heatmap as
+// a PNG, and lists the groups that vote alike.
+func exportAffinity(opts *options, paths []string, csvPath, pngPath string) int {
+	cards, failed := loadDecks(paths)
+	if failed {
+		fmt.Println("affinity: not measuring decks with missing cards")
+		return exitFailures
+	}
+	affinity := deckAffinity(cards, opts.Copies)
+	if affinity.Cards == 0 {
+		fmt.Println("affinity: the decks have no legislation cards")
+		return exitFailures
+	}
+
+	var out bytes.Buffer
+	if err := writeAffinityCSV(&out, affinity); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	if err := writeFileAtomic(csvPath, out.Bytes()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	opts.printf(normal, "Wrote %s\n", csvPath)
+
+	heatmap, err := renderer.DrawAffinity(affinity, affinityCell)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	out.Reset()
+	if err := png.Encode(&out, heatmap); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	if err := writeFileAtomic(pngPath, out.Bytes()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	opts.printf(normal, "Wrote %s\n", pngPath)
+
+	for _, pair := range affinity.Alike(opts.Alike) {
+		fmt.Printf("%s and %s vote alike, similarity %.2f\n", game.Groups[pair.First].Name, game.Groups[pair.Second].Name, pair.Similarity)
+	}
+	return exitOK
+}
+
+// affinityOutputs returns the files to write, in the output directory
+// unless given.
+func affinityOutputs(opts *options) (csvPath, pngPath string, err error) {
+	csvPath, pngPath = opts.CSV, opts.Output
+	if csvPath != "" && pngPath != "" {
+		return csvPath, pngPath, nil
+	}
+	if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+		return "", "", fmt.Errorf("Critical error - create directory %s yourself", opts.OutDir)
+	}
+	if csvPath == "" {
+		csvPath = filepath.Join(opts.OutDir, "affinity.csv")
+	}
+	if pngPath == "" {
+		pngPath = filepath.Join(opts.OutDir, "affinity.png")
+	}
+	return csvPath, pngPath, nil
+}
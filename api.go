@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nfnt/resize"
+
+	"sejm_generator/sejm"
+)
+
+// apiFormats are the formats the render API draws cards in, by the name
+// of the format query parameter.
+var apiFormats = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"webp": "image/webp",
+	"pdf":  "application/pdf",
+}
+
+// apiFormatOrder is the order formats are preferred in when an Accept
+// header allows several equally.
+var apiFormatOrder = []string{"png", "jpeg", "webp", "pdf"}
+
+// apiServer renders cards for other services. Every request draws with the
+// same renderer, so the assets are decoded once for all of them.
+type apiServer struct {
+	opts     *options
+	renderer *sejm.Renderer
+	rules    *sejm.Rules   // cards breaking these can't be drawn
+	slots    chan struct{} // one per card being drawn
+}
+
+// apiError is the body of every failed API request. Field, Value and
+// Column point at the part of the card that is wrong, when one is, and
+// Rule names the validation rule it breaks.
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Rule    string `json:"rule,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// vocabulary lists the groups, indicators, currencies and symbols of a
+// game with the names they go by. Icons are asset names.
+type vocabulary struct {
+	Groups              []vocabularyName `json:"groups"`
+	Indicators          []vocabularyName `json:"indicators"`
+	Currencies          []vocabularyName `json:"currencies"`
+	Symbols             []vocabularyName `json:"symbols"`
+	LegislationCurrency sejm.Currency    `json:"legislation_currency"`
+}
+
+type vocabularyName struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	Icons   []string `json:"icons"`
+}
+
+func newVocabulary(game *sejm.Game) vocabulary {
+	v := vocabulary{LegislationCurrency: game.LegislationCurrency}
+	for _, group := range game.Groups {
+		v.Groups = append(v.Groups, vocabularyName{group.ID, group.Name, group.Aliases, []string{group.Stamp, group.StrongStamp}})
+	}
+	for _, indicator := range game.Indicators {
+		v.Indicators = append(v.Indicators, vocabularyName{indicator.ID, indicator.Name, indicator.Aliases, []string{indicator.Plus, indicator.Minus}})
+	}
+	for _, currency := range game.Currencies {
+		v.Currencies = append(v.Currencies, vocabularyName{string(currency.ID), currency.Name, currency.Aliases, []string{path.Join(currency.Icons, "plus1.png")}})
+	}
+	for _, symbol := range game.Symbols {
+		v.Symbols = append(v.Symbols, vocabularyName{string(symbol.ID), symbol.Name, symbol.Aliases, []string{symbol.Icon}})
+	}
+	return v
+}
+
+func newAPIServer(opts *options) *apiServer {
+	art := os.DirFS(opts.ArtDir)
+	s := &apiServer{
+		opts: opts,
+		renderer: sejm.NewRenderer(sejm.Config{
+			Assets: assetSource,
+			Layout: layout,
+			Game:   game,
+			// Art is looked up in the art directory only, whatever the
+			// card asks for
+			OpenArt: func(name string) (io.ReadCloser, error) {
+				return art.Open(name)
+			},
+		}),
+		slots: make(chan struct{}, opts.Workers),
+	}
+	s.rules = apiRules(s.renderer)
+	return s
+}
+
+// apiRules are the rules of the renderer that cards breaking can't be
+// drawn at all. Art is checked by drawing the card, and cards breaking the
+// other rules draw, if not as intended.
+func apiRules(renderer *sejm.Renderer) *sejm.Rules {
+	rules := renderer.DefaultRules()
+	rules.Currency.Severity = sejm.SeverityOff
+	rules.Art.Severity = sejm.SeverityOff
+	rules.TextFit.Severity = sejm.SeverityOff
+	rules.ExtraFields.Severity = sejm.SeverityOff
+	return rules
+}
+
+func (s *apiServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/render", s.render)
+	mux.HandleFunc("GET /v1/vocabulary", s.vocabulary)
+	mux.HandleFunc("GET /v1/health", s.health)
+	return mux
+}
+
+func (s *apiServer) vocabulary(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, newVocabulary(game))
+}
+
+func (s *apiServer) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "version": Version})
+}
+
+// render draws the card of the request body, a card of a JSON deck or a
+// line of a card code deck, in the format asked for by the format
+// parameter or the Accept header. width scales the card down.
+func (s *apiServer) render(w http.ResponseWriter, r *http.Request) {
+	format, err := apiFormat(r)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	width := 0
+	if value := r.URL.Query().Get("width"); value != "" {
+		if width, err = strconv.Atoi(value); err != nil || width < 1 || width > layout.Width {
+			writeAPIError(w, &apiError{Status: http.StatusBadRequest, Code: "invalid_width",
+				Message: fmt.Sprintf("width must be a number of pixels from 1 to %d", layout.Width), Value: value})
+			return
+		}
+	}
+	card, err := s.card(w, r)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.opts.Timeout)
+	defer cancel()
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		writeAPIError(w, &apiError{Status: http.StatusServiceUnavailable, Code: "busy", Message: "every worker stayed busy, try again later"})
+		return
+	}
+	img, err := s.renderer.Render(ctx, card)
+	<-s.slots
+	if err != nil {
+		writeAPIError(w, renderFailure(err))
+		return
+	}
+	if width > 0 && width < img.Bounds().Dx() {
+		img = resize.Resize(uint(width), 0, img, resize.Lanczos3)
+	}
+
+	var out bytes.Buffer
+	if format == "pdf" {
+		cardWidth, cardHeight := layout.TrimSize()
+		err = sejm.EncodePDF(&out, img, cardWidth, cardHeight)
+	} else {
+		err = sejm.Encode(&out, img, format, sejm.NewCardMetadata(card, Version))
+	}
+	if err != nil {
+		writeAPIError(w, &apiError{Status: http.StatusInternalServerError, Code: "encode_failed", Message: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", apiFormats[format])
+	w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+	w.Write(out.Bytes())
+}
+
+// apiFormat picks the format of a render request.
+func apiFormat(r *http.Request) (string, error) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		if format == "jpg" {
+			format = "jpeg"
+		}
+		if _, ok := apiFormats[format]; !ok {
+			return "", &apiError{Status: http.StatusBadRequest, Code: "unsupported_format",
+				Message: "format must be png, jpeg, webp or pdf", Field: "format", Value: format}
+		}
+		return format, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return "png", nil
+	}
+	if format := acceptedFormat(accept); format != "" {
+		return format, nil
+	}
+	return "", &apiError{Status: http.StatusNotAcceptable, Code: "unsupported_format",
+		Message: "Accept must allow image/png, image/jpeg, image/webp or application/pdf", Value: accept}
+}
+
+// acceptedFormat returns the format an Accept header prefers, or "" for
+// none. Each format takes the q-value of the most specific media range
+// matching it; q=0 refuses it. Formats of the same q-value go by the
+// first range naming them, then in the order of apiFormatOrder, so that
+// wildcards give PNG.
+func acceptedFormat(accept string) string {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+
+	best, bestQ, bestRange := "", 0.0, 0
+	for _, format := range apiFormatOrder {
+		formatType := apiFormats[format]
+		q, matched, specificity := 0.0, 0, 0
+		for i, r := range ranges {
+			s := 0
+			switch {
+			case r.mediaType == formatType:
+				s = 3
+			case strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(formatType, strings.TrimSuffix(r.mediaType, "*")):
+				s = 2
+			case r.mediaType == "*/*":
+				s = 1
+			}
+			if s > specificity {
+				q, matched, specificity = r.q, i, s
+			}
+		}
+		if q > bestQ || q > 0 && q == bestQ && matched < bestRange {
+			best, bestQ, bestRange = format, q, matched
+		}
+	}
+	return best
+}
+
+// card reads the card of a render request, no larger than the body limit.
+func (s *apiServer) card(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.opts.MaxBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, &apiError{Status: http.StatusRequestEntityTooLarge, Code: "too_large",
+				Message: fmt.Sprintf("cards can be at most %d bytes", s.opts.MaxBody)}
+		}
+		return nil, &apiError{Status: http.StatusBadRequest, Code: "invalid_body", Message: err.Error()}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var card interface{}
+	switch mediaType {
+	case "text/plain":
+		// A line of a card code deck
+		marker, code, found := strings.Cut(strings.TrimSpace(string(body)), ":")
+		kind, ok := parseKind(marker)
+		if !found || !ok {
+			return nil, &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_card",
+				Message: `missing card type, expected "legislation:" or "action:"`}
+		}
+		card, err = game.ParseCard(kind, strings.TrimSpace(code))
+	case "", "application/json":
+		var def CardDef
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&def); err != nil {
+			return nil, &apiError{Status: http.StatusBadRequest, Code: "invalid_json", Message: err.Error()}
+		}
+		card, err = def.Card()
+	default:
+		return nil, &apiError{Status: http.StatusUnsupportedMediaType, Code: "unsupported_media_type",
+			Message: "send cards as application/json or text/plain", Value: mediaType}
+	}
+	if err == nil {
+		err = game.CheckCard(card)
+	}
+	if err != nil {
+		invalid := &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_card", Message: err.Error()}
+		var parseErr *sejm.ParseError
+		if errors.As(err, &parseErr) {
+			invalid.Field, invalid.Value, invalid.Column = parseErr.Field, parseErr.Value, parseErr.Column
+		}
+		return nil, invalid
+	}
+	for _, problem := range s.renderer.Validate(card, s.rules) {
+		if problem.Severity == sejm.SeverityError {
+			return nil, &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_card",
+				Message: problem.Field + ": " + problem.Message, Rule: problem.Rule, Field: problem.Field}
+		}
+	}
+	return card, nil
+}
+
+// renderFailure tells the cards that can't be drawn as they are, for
+// want of their art, from the server failing to draw them.
+func renderFailure(err error) *apiError {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &apiError{Status: http.StatusServiceUnavailable, Code: "timeout", Message: "drawing the card took too long"}
+	}
+	var artErr *sejm.ArtError
+	if errors.As(err, &artErr) {
+		code := "invalid_art"
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			code = "art_not_found"
+		}
+		return &apiError{Status: http.StatusUnprocessableEntity, Code: code, Message: err.Error(), Field: "art", Value: artErr.Path}
+	}
+	return &apiError{Status: http.StatusInternalServerError, Code: "render_failed", Message: err.Error()}
+}
+
+func writeAPIError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = &apiError{Status: http.StatusInternalServerError, Code: "internal", Message: err.Error()}
+	}
+	writeJSON(w, apiErr.Status, map[string]*apiError{"error": apiErr})
+}
+
+// serveAPI runs the render API on addr until the server fails.
+func serveAPI(opts *options) int {
+	s := newAPIServer(opts)
+	server := &http.Server{
+		Addr:              opts.Addr,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       opts.Timeout,
+		WriteTimeout:      2 * opts.Timeout,
+	}
+
+	fmt.Printf("Render API on http://%s/v1/, art from %s, %d workers\n", displayAddr(opts.Addr), opts.ArtDir, opts.Workers)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return exitOK
+}
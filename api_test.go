@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAPIServer serves the render API with art from a temporary
+// directory holding a.png.
+func newTestAPIServer(t *testing.T) *apiServer {
+	dir := t.TempDir()
+	writeTestArt(t, filepath.Join(dir, "a.png"))
+	return newAPIServer(&options{ArtDir: dir, Workers: 2, MaxBody: 1 << 10, Timeout: 10 * time.Second})
+}
+
+func TestAPIRender(t *testing.T) {
+	s := newTestAPIServer(t)
+	tests := []struct {
+		contentType, accept, body string
+		want                      string
+	}{
+		{"application/json", "", `{"type": "action", "art": "a", "title": "Akcja", "cost": {"value": 2, "currency": "cash"}}`, "image/png"},
+		{"", "image/jpeg", `{"type": "legislation", "art": "a", "title": "Ustawa", "opinions": {"kat": 1}}`, "image/jpeg"},
+		{"text/plain", "application/pdf", "action: a>Akcja>Opis>none>>0", "application/pdf"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/v1/render", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		r.Header.Set("Accept", test.accept)
+		w := httptest.NewRecorder()
+		s.routes().ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != test.want {
+			t.Errorf("%s: %d %s %s, want 200 %s", test.body, w.Code, w.Header().Get("Content-Type"), w.Body, test.want)
+		}
+	}
+}
+
+func TestAPIFormat(t *testing.T) {
+	tests := []struct {
+		target, accept string
+		want           string
+	}{
+		{"/v1/render", "", "png"},
+		{"/v1/render", "*/*", "png"},
+		{"/v1/render", "image/*", "png"},
+		{"/v1/render", "application/*", "pdf"},
+		{"/v1/render", "image/webp", "webp"},
+		{"/v1/render", "image/webp, image/png", "webp"},
+		{"/v1/render", "image/webp;q=0, image/png", "png"},
+		{"/v1/render", "image/webp;q=0.5, image/jpeg;q=0.8", "jpeg"},
+		{"/v1/render", "image/png;q=0.1, image/*;q=0.9", "jpeg"},
+		{"/v1/render", "image/png;q=0, */*", "jpeg"},
+		{"/v1/render", "text/html, application/pdf;q=0.2, image/gif", "pdf"},
+		{"/v1/render", "image/webp;q=2, image/jpeg;q=x, image/png;q=0.3", "png"},
+		{"/v1/render?format=jpg", "image/png", "jpeg"},
+		{"/v1/render?format=WEBP", "", "webp"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", test.target, nil)
+		r.Header.Set("Accept", test.accept)
+		if got, err := apiFormat(r); err != nil || got != test.want {
+			t.Errorf("%s with Accept %q: %q, %v, want %q", test.target, test.accept, got, err, test.want)
+		}
+	}
+
+	for _, accept := range []string{"image/gif", "image/*;q=0", "image/png;q=0, application/pdf;q=0, image/jpeg;q=0, image/webp;q=0"} {
+		r := httptest.NewRequest("POST", "/v1/render", nil)
+		r.Header.Set("Accept", accept)
+		var apiErr *apiError
+		if _, err := apiFormat(r); !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotAcceptable {
+			t.Errorf("Accept %q: %v, want 406", accept, err)
+		}
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	s := newTestAPIServer(t)
+	tests := []struct {
+		target, contentType, body string
+		status                    int
+		want                      apiError
+	}{
+		{"/v1/render?format=gif", "", `{}`, http.StatusBadRequest, apiError{Code: "unsupported_format", Field: "format", Value: "gif"}},
+		{"/v1/render?width=0", "", `{}`, http.StatusBadRequest, apiError{Code: "invalid_width", Value: "0"}},
+		{"/v1/render", "", `{"type": "action",`, http.StatusBadRequest, apiError{Code: "invalid_json"}},
+		{"/v1/render", "", `{"type": "action", "colour": "red"}`, http.StatusBadRequest, apiError{Code: "invalid_json"}},
+		{"/v1/render", "", `{"title": "` + strings.Repeat("x", 2<<10) + `"}`, http.StatusRequestEntityTooLarge, apiError{Code: "too_large"}},
+		{"/v1/render", "image/png", `{}`, http.StatusUnsupportedMediaType, apiError{Code: "unsupported_media_type", Value: "image/png"}},
+		{"/v1/render", "text/plain", "a>Akcja>Opis>none>>0", http.StatusUnprocessableEntity, apiError{Code: "invalid_card"}},
+		{"/v1/render", "text/plain", "action: a>Akcja>Opis>kapelusz>>0", http.StatusUnprocessableEntity,
+			apiError{Code: "invalid_card", Field: "symbol", Value: "kapelusz", Column: 14}},
+		{"/v1/render", "", `{"type": "legislation", "art": "a", "title": "T", "opinions": {"kat": 1, "prg": 1, "soc": 1, "pzc": 1, "rob": 1}}`,
+			http.StatusUnprocessableEntity, apiError{Code: "invalid_card", Rule: "stamp_slots", Field: "opinions"}},
+		{"/v1/render", "", `{"type": "action", "art": "a", "title": "T", "cost": {"value": 9, "currency": "cash"}}`,
+			http.StatusUnprocessableEntity, apiError{Code: "invalid_card", Rule: "cost_range", Field: "cost"}},
+		{"/v1/render", "", `{"type": "action", "art": "b", "title": "T"}`, http.StatusUnprocessableEntity,
+			apiError{Code: "art_not_found", Field: "art", Value: "b.png"}},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", test.target, strings.NewReader(test.body))
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+		w := httptest.NewRecorder()
+		s.routes().ServeHTTP(w, r)
+
+		var body struct {
+			Error apiError `json:"error"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Errorf("%s %.40s: %v", test.target, test.body, err)
+			continue
+		}
+		got := body.Error
+		got.Message = ""
+		if w.Code != test.status || got != test.want {
+			t.Errorf("%s %.40s: %d %+v, want %d %+v", test.target, test.body, w.Code, got, test.status, test.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"sejm_generator/assets"
+	"sejm_generator/sejm"
+)
+
+// AssetRoot is a directory or an asset-pack zip whose files replace the
+// built-in assets of the same name. Empty uses the built-in assets only.
+var AssetRoot = ""
+
+var builtinAssets = sejm.AssetLayer{Name: "built-in", FS: assets.FS}
+
+var (
+	// assetSource serves the assets the cards are drawn from
+	assetSource = sejm.NewAssetSource(builtinAssets)
+	// assetPack is the open asset-pack zip, if any
+	assetPack io.Closer
+)
+
+// openAssets puts AssetRoot in front of the built-in assets and sets up
+// the renderer to draw from them.
+func openAssets() error {
+	layers := []sejm.AssetLayer{builtinAssets}
+	var pack io.Closer
+	if AssetRoot != "" {
+		custom, closer, err := openAssetRoot(AssetRoot)
+		if err != nil {
+			return err
+		}
+		layers = append([]sejm.AssetLayer{{Name: AssetRoot, FS: custom}}, layers...)
+		pack = closer
+	}
+
+	if assetPack != nil {
+		assetPack.Close()
+	}
+	assetPack = pack
+	assetSource = sejm.NewAssetSource(layers...)
+	renderer = newRenderer()
+	return nil
+}
+
+// openAssetRoot opens an asset directory or an asset-pack zip. The closer
+// is nil for directories.
+func openAssetRoot(path string) (fs.FS, io.Closer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("assets: %v", err)
+	}
+	if info.IsDir() {
+		return os.DirFS(path), nil, nil
+	}
+
+	pack, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("assets: %s is neither a directory nor an asset-pack zip: %v", path, err)
+	}
+	// A pack zipped together with its folder has that folder as its only
+	// entry, unless the folder is one of the asset directories
+	entries, err := fs.ReadDir(pack, ".")
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		if _, err := fs.Stat(assets.FS, entries[0].Name()); err != nil {
+			sub, err := fs.Sub(pack, entries[0].Name())
+			if err == nil {
+				return sub, pack, nil
+			}
+		}
+	}
+	return pack, pack, nil
+}
+
+// isAssetPack reports whether AssetRoot is an asset-pack zip.
+func isAssetPack() bool {
+	info, err := os.Stat(AssetRoot)
+	return AssetRoot != "" && err == nil && !info.IsDir()
+}
+
+// assetFiles returns the files whose changes can change an asset: its
+// file in the asset directory, or the asset pack.
+func assetFiles(name string) []string {
+	switch {
+	case AssetRoot == "":
+		return nil
+	case isAssetPack():
+		return []string{AssetRoot}
+	}
+	return []string{filepath.Join(AssetRoot, filepath.FromSlash(name))}
+}
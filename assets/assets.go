@@ -0,0 +1,11 @@
+// Package assets holds the card templates, stamps, icons and font of the
+// printed game, compiled into the generator.
+package assets
+
+import "embed"
+
+// FS holds the assets under the names the renderer looks them up by, like
+// "print_card.png" and "grupy/kat.png".
+//
+//go:embed *.png *.ttf ceny grupy symbol wsk
+var FS embed.FS
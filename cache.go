@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"sejm_generator/sejm"
+)
+
+// CacheFileName is the build cache kept in the output directory.
+const CacheFileName = ".sejm-cache.json"
+
+// BuildCache remembers the inputs each output was rendered from, so that
+// cards whose inputs haven't changed are not drawn again. It is safe for
+// concurrent use.
+type BuildCache struct {
+	path   string
+	outDir string
+
+	mu      sync.Mutex
+	Entries map[string]string `json:"entries"` // output path within outDir -> input hash
+	files   map[string]string // file path -> content hash, for this run
+	dirty   bool
+}
+
+func cachePath(outDir string) string {
+	return filepath.Join(outDir, CacheFileName)
+}
+
+// LoadBuildCache reads the cache of an output directory. A missing or
+// unreadable cache is an empty one.
+func LoadBuildCache(outDir string) *BuildCache {
+	cache := &BuildCache{
+		path:    cachePath(outDir),
+		outDir:  outDir,
+		Entries: map[string]string{},
+		files:   map[string]string{},
+	}
+	data, err := os.ReadFile(cache.path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, cache); err != nil || cache.Entries == nil {
+		cache.Entries = map[string]string{}
+	}
+	return cache
+}
+
+// Save writes the cache back if anything changed.
+func (cache *BuildCache) Save() error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if !cache.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(cache.path, append(data, '\n')); err != nil {
+		return err
+	}
+	cache.dirty = false
+	return nil
+}
+
+// Key hashes everything the rendered card depends on: the generator with
+// its built-in assets, the output format, the card definition and the
+// bytes of the art and of every custom asset it draws.
+func (cache *BuildCache) Key(card interface{}) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "generator %s %s\n", Version, executableHash())
+	fmt.Fprintf(hash, "format %s\n", OutputFormat)
+	fmt.Fprintf(hash, "card %s\n", sejm.NewCardMetadata(card, Version).Line())
+
+	deps := cardDependencies(card)
+	sort.Strings(deps)
+	for _, dep := range deps {
+		fmt.Fprintf(hash, "file %s %s\n", dep, cache.fileHash(dep))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (cache *BuildCache) fileHash(path string) string {
+	cache.mu.Lock()
+	sum, ok := cache.files[path]
+	cache.mu.Unlock()
+	if ok {
+		return sum
+	}
+
+	sum = hashFile(path)
+	cache.mu.Lock()
+	cache.files[path] = sum
+	cache.mu.Unlock()
+	return sum
+}
+
+func hashFile(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return "missing"
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "unreadable"
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+var (
+	executableHashOnce sync.Once
+	executableHashSum  string
+)
+
+// executableHash identifies the running binary, so that a rebuilt
+// generator re-renders everything even when Version stays "dev".
+func executableHash() string {
+	executableHashOnce.Do(func() {
+		executableHashSum = "unknown"
+		if path, err := os.Executable(); err == nil {
+			executableHashSum = hashFile(path)
+		}
+	})
+	return executableHashSum
+}
+
+func (cache *BuildCache) entryName(output string) string {
+	if rel, err := filepath.Rel(cache.outDir, output); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(output)
+}
+
+// UpToDate reports whether output exists and was rendered from inputs
+// with the given key.
+func (cache *BuildCache) UpToDate(output, key string) bool {
+	cache.mu.Lock()
+	stored, ok := cache.Entries[cache.entryName(output)]
+	cache.mu.Unlock()
+	if !ok || stored != key {
+		return false
+	}
+	_, err := os.Stat(output)
+	return err == nil
+}
+
+// Record notes that output was rendered from inputs with the given key.
+func (cache *BuildCache) Record(output, key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.Entries[cache.entryName(output)] = key
+	cache.dirty = true
+}
+
+// Forget drops output from the cache, after a failed render.
+func (cache *BuildCache) Forget(output string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	name := cache.entryName(output)
+	if _, ok := cache.Entries[name]; ok {
+		delete(cache.Entries, name)
+		cache.dirty = true
+	}
+}
+
+// Clean removes every output recorded in the cache and the cache itself.
+// It returns the removed files.
+func (cache *BuildCache) Clean() ([]string, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var removed []string
+	names := make([]string, 0, len(cache.Entries))
+	for name := range cache.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		output := filepath.Join(cache.outDir, filepath.FromSlash(name))
+		err := os.Remove(output)
+		if err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		if err == nil {
+			removed = append(removed, output)
+		}
+		delete(cache.Entries, name)
+	}
+
+	err := os.Remove(cache.path)
+	if err != nil && !os.IsNotExist(err) {
+		return removed, err
+	}
+	cache.dirty = false
+	return removed, nil
+}
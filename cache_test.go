@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sejm_generator/sejm"
+)
+
+func TestBuildCacheKey(t *testing.T) {
+	chdirTemp(t)
+	writeTestArt(t, "a.png")
+	card := sejm.NewActionCard("a", "Akcja", "Opis", sejm.NoSymbol, 1, "cash", "")
+	key := LoadBuildCache("out").Key(card)
+	if again := LoadBuildCache("out").Key(card); again != key {
+		t.Errorf("key of the same card changed from %s to %s", key, again)
+	}
+
+	changed := card
+	changed.Title = "Inna akcja"
+	if LoadBuildCache("out").Key(changed) == key {
+		t.Errorf("key didn't change with the title")
+	}
+
+	defer func(format string) { OutputFormat = format }(OutputFormat)
+	OutputFormat = "jpeg"
+	if LoadBuildCache("out").Key(card) == key {
+		t.Errorf("key didn't change with the output format")
+	}
+	OutputFormat = "png"
+
+	// Files are hashed once a run, a new cache sees the new art
+	cache := LoadBuildCache("out")
+	cache.Key(card)
+	if err := os.WriteFile("a.png", []byte("new art"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Key(card) != key {
+		t.Errorf("key changed within a run")
+	}
+	if LoadBuildCache("out").Key(card) == key {
+		t.Errorf("key didn't change with the art")
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	dir := chdirTemp(t)
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(out, "a.png")
+
+	cache := LoadBuildCache(out)
+	cache.Record(output, "key")
+	if cache.UpToDate(output, "key") {
+		t.Errorf("up to date without the output")
+	}
+	if err := os.WriteFile(output, []byte("card"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache = LoadBuildCache(out)
+	if !cache.UpToDate(output, "key") {
+		t.Errorf("not up to date after saving and loading")
+	}
+	if cache.UpToDate(output, "other key") {
+		t.Errorf("up to date with another key")
+	}
+
+	removed, err := cache.Clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != output {
+		t.Errorf("Clean removed %v, want %s", removed, output)
+	}
+	for _, path := range []string{output, cachePath(out)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s is still there after Clean", path)
+		}
+	}
+}
@@ -0,0 +1,949 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"sejm_generator/sejm"
+)
+
+// Exit codes returned by the CLI
+const (
+	exitOK       = 0 // everything succeeded
+	exitFailures = 1 // some cards failed to parse or render
+	exitUsage    = 2 // bad command line
+	exitIO       = 3 // output directory or input files unusable
+)
+
+const (
+	quiet = iota
+	normal
+	verbose
+)
+
+type options struct {
+	AssetRoot string
+	OutDir    string
+	Format    string
+	Columns   string
+	Layout    string
+	Game      string
+	Verbosity int
+
+	// Command specific
+	To       string
+	Output   string
+	Named    bool
+	List     bool
+	Force    bool
+	Workers  int
+	Runs     int
+	Interval time.Duration
+
+	// Statistics
+	CSV, HTML string
+	Alike     float64
+	Model     string
+	Seed      int64
+
+	// Validation
+	Rules  string
+	JSON   bool
+	Strict bool
+
+	// Printing
+	Paper                             string
+	Landscape                         bool
+	Grid                              string
+	Margin, Gutter, Bleed             float64
+	CropMarks                         bool
+	Copies                            int
+	Back, LegislationBack, ActionBack string
+	Flip                              string
+
+	// Tabletop Simulator
+	Name      string
+	BaseURL   string
+	SheetSize int
+
+	// Editor and render API
+	Addr    string
+	ArtDir  string
+	MaxBody int64
+	Timeout time.Duration
+
+	// VASSAL
+	Players   int
+	CardWidth int
+
+	verbose, quiet bool
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(opts *options, args []string) int
+	flags   func(flags *flag.FlagSet, opts *options)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"render", "<deck file>...", "render the cards of the given deck files that changed since the last render", cmdRender, renderFlags},
+		{"watch", "<deck file>...", "render decks and re-render cards whenever their files change", cmdWatch, watchFlags},
+		{"serve", "[deck file]", "edit cards in the browser with a live preview, saving them to the deck", cmdServe, serveFlags},
+		{"api", "", "serve a JSON-over-HTTP API that renders cards for other services", cmdAPI, apiFlags},
+		{"pdf", "<deck file>...", "lay out the cards of decks on print sheets in a PDF", cmdPDF, pdfFlags},
+		{"export", "<format> [flags] <deck file>...", "export decks for playing them online: " + strings.Join(exporterNames(), " or "), cmdExport, nil},
+		{"assets", "", "list the assets and where each one comes from", cmdAssets, nil},
+		{"clean", "", "remove rendered cards and the build cache from the output directory", cmdClean, nil},
+		{"stats", "<deck file>...", "count opinions, effects and costs across decks, for balancing them", cmdStats, statsFlags},
+		{"affinity", "<deck file>...", "measure which groups vote alike on legislation, as CSV and a heatmap", cmdAffinity, affinityFlags},
+		{"simulate", "<deck file>...", "estimate how often legislation passes from many seeded random votes of the Sejm", cmdSimulate, simulateFlags},
+		{"validate", "<deck file>...", "check the cards of the given deck files against the design rules", cmdValidate, validateFlags},
+		{"convert", "<deck file>...", "convert decks to card codes, JSON or YAML", cmdConvert, convertFlags},
+		{"fmt", "<deck file>...", "rewrite deck files in canonical form", cmdFmt, fmtFlags},
+		{"decode", "<card image>...", "print the definition stored in generated card PNGs", cmdDecode, decodeFlags},
+		{"schema", "", "print the JSON Schema of JSON and YAML decks", cmdSchema, nil},
+		{"layout", "", "print the default card layout, to start a custom one from", cmdLayout, nil},
+		{"game", "", "print the default game definition, to start a custom one from", cmdGame, nil},
+		{"interactive", "", "type card codes one at a time (the default without a command)", cmdInteractive, nil},
+		{"help", "[command]", "show usage", cmdHelp, nil},
+	}
+}
+
+// exporters are the formats of the export command, run as commands of
+// their own named "export <format>".
+var exporters = []command{
+	{"tts", "<deck file>...", "a Tabletop Simulator saved object with deck sheets", cmdTTS, ttsFlags},
+	{"vassal", "<deck file>...", "a VASSAL module with draw piles, hands and counters", cmdVassal, vassalFlags},
+}
+
+func exporterNames() []string {
+	var names []string
+	for _, exporter := range exporters {
+		names = append(names, exporter.name)
+	}
+	return names
+}
+
+func runCLI(args []string) int {
+	if len(args) == 0 {
+		return cmdInteractive(defaultOptions(), nil)
+	}
+
+	name := args[0]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return runCommand(cmd, defaultOptions(), args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return exitUsage
+}
+
+// runCommand parses the flags of a command over opts and runs it.
+func runCommand(cmd command, opts *options, args []string) int {
+	flags := newFlagSet(cmd, opts)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := opts.apply(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	return cmd.run(opts, flags.Args())
+}
+
+// findExporter returns the exporter of a format.
+func findExporter(format string) (command, bool) {
+	for _, exporter := range exporters {
+		if exporter.name == format {
+			exporter.name = "export " + exporter.name
+			return exporter, true
+		}
+	}
+	return command{}, false
+}
+
+// cmdExport runs the exporter of the format given first, with the flags
+// given before it as defaults.
+func cmdExport(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "export: no format given")
+		printExporters(os.Stderr)
+		return exitUsage
+	}
+	exporter, ok := findExporter(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "export: unknown format %q\n", args[0])
+		printExporters(os.Stderr)
+		return exitUsage
+	}
+	return runCommand(exporter, opts, args[1:])
+}
+
+func printExporters(w io.Writer) {
+	fmt.Fprintln(w, "formats:")
+	for _, exporter := range exporters {
+		fmt.Fprintf(w, "  %-12s %s\n", exporter.name, exporter.summary)
+	}
+}
+
+func defaultOptions() *options {
+	return &options{
+		AssetRoot: AssetRoot,
+		OutDir:    DirName,
+		Format:    OutputFormat,
+		Columns:   SpreadsheetColumns,
+		Layout:    LayoutFile,
+		Game:      GameFile,
+		Verbosity: normal,
+	}
+}
+
+func newFlagSet(cmd command, opts *options) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.StringVar(&opts.AssetRoot, "assets", opts.AssetRoot, "`directory` or asset-pack zip of assets replacing the built-in ones")
+	flags.StringVar(&opts.OutDir, "out", opts.OutDir, "output `directory`")
+	flags.StringVar(&opts.Format, "format", opts.Format, "output format: png or jpeg")
+	flags.StringVar(&opts.Columns, "columns", opts.Columns, "column mapping `file` for CSV and TSV decks")
+	flags.StringVar(&opts.Layout, "layout", opts.Layout, "JSON or YAML layout `file` to draw cards with")
+	flags.StringVar(&opts.Game, "game", opts.Game, "JSON or YAML game definition `file` of the groups, indicators, currencies and symbols")
+	flags.BoolVar(&opts.verbose, "v", false, "verbose output")
+	flags.BoolVar(&opts.quiet, "q", false, "only print failures and the summary")
+	if cmd.flags != nil {
+		cmd.flags(flags, opts)
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: sejm_generator %s [flags] %s\n", cmd.name, cmd.args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// apply checks the options, publishes them to the renderer and loads the
+// game definition every command reads cards with.
+func (opts *options) apply() error {
+	if opts.verbose {
+		opts.Verbosity = verbose
+	} else if opts.quiet {
+		opts.Verbosity = quiet
+	}
+	switch strings.ToLower(opts.Format) {
+	case "png":
+		opts.Format = "png"
+	case "jpeg", "jpg":
+		opts.Format = "jpeg"
+	default:
+		return fmt.Errorf("unknown output format %q, expected png or jpeg", opts.Format)
+	}
+
+	AssetRoot = opts.AssetRoot
+	OutputFormat = opts.Format
+	SpreadsheetColumns = opts.Columns
+	LayoutFile = opts.Layout
+	GameFile = opts.Game
+	return loadGame()
+}
+
+// checkAssets is called by the commands that draw cards.
+func (opts *options) checkAssets() bool {
+	if err := loadLayout(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	if err := openAssets(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	return true
+}
+
+func (opts *options) printf(level int, format string, args ...interface{}) {
+	if opts.Verbosity >= level {
+		fmt.Printf(format, args...)
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: sejm_generator <command> [flags] [arguments]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Run 'sejm_generator help <command>' for the flags of a command.")
+}
+
+func cmdHelp(opts *options, args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	if args[0] == "export" && len(args) > 1 {
+		exporter, ok := findExporter(args[1])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown export format %q\n", args[1])
+			return exitUsage
+		}
+		flags := newFlagSet(exporter, defaultOptions())
+		flags.SetOutput(os.Stdout)
+		flags.Usage()
+		return exitOK
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			flags := newFlagSet(cmd, defaultOptions())
+			flags.SetOutput(os.Stdout)
+			flags.Usage()
+			if cmd.name == "export" {
+				fmt.Println()
+				printExporters(os.Stdout)
+				fmt.Println("\nRun 'sejm_generator help export <format>' for the flags of a format.")
+			}
+			return exitOK
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	return exitUsage
+}
+
+func cmdInteractive(opts *options, args []string) int {
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+	return menu(opts.OutDir)
+}
+
+func renderFlags(flags *flag.FlagSet, opts *options) {
+	flags.BoolVar(&opts.Force, "force", false, "render every card, even if it is up to date")
+	flags.IntVar(&opts.Workers, "j", runtime.GOMAXPROCS(0), "number of cards to render at the same time")
+}
+
+func cmdRender(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "render: no deck files given")
+		return exitUsage
+	}
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+	if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Critical error - create directory %s yourself\n", opts.OutDir)
+		return exitIO
+	}
+
+	cache := LoadBuildCache(opts.OutDir)
+	if opts.Force {
+		cache.Entries = map[string]string{}
+	}
+	code := runDecks(opts, args, "Rendered", "Generated", normal, func(path string) DeckReport {
+		opts.printf(verbose, "Rendering %s into %s\n", path, opts.OutDir)
+		return renderDeck(path, opts.OutDir, cache, opts.Workers)
+	})
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save the build cache: %v\n", err)
+	}
+	return code
+}
+
+func serveFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Addr, "addr", "localhost:8080", "`address` to serve the editor on")
+	flags.BoolVar(&opts.Named, "named", false, "write opinions and effects by name when saving to card code decks")
+}
+
+func cmdServe(opts *options, args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "serve: takes at most one deck file")
+		return exitUsage
+	}
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+	deck := ""
+	if len(args) == 1 {
+		deck = args[0]
+	}
+	return serveEditor(opts, deck)
+}
+
+func apiFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Addr, "addr", "localhost:8090", "`address` to serve the API on")
+	flags.StringVar(&opts.ArtDir, "art", ".", "`directory` card art is read from, nothing outside of it is")
+	flags.Int64Var(&opts.MaxBody, "max-body", 64<<10, "largest card request in `bytes`")
+	flags.IntVar(&opts.Workers, "j", runtime.GOMAXPROCS(0), "number of cards to render at the same time")
+	flags.DurationVar(&opts.Timeout, "timeout", 30*time.Second, "longest a request may take, waiting for a worker included")
+}
+
+func cmdAPI(opts *options, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "api: takes no arguments")
+		return exitUsage
+	}
+	if opts.MaxBody < 1 || opts.Workers < 1 || opts.Timeout <= 0 {
+		fmt.Fprintln(os.Stderr, "api: -max-body, -j and -timeout must be positive")
+		return exitUsage
+	}
+	if info, err := os.Stat(opts.ArtDir); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "api: -art %s is not a directory\n", opts.ArtDir)
+		return exitUsage
+	}
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+	return serveAPI(opts)
+}
+
+func pdfFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Output, "o", "", "PDF `file` to write, cards.pdf in the output directory by default")
+	flags.StringVar(&opts.Paper, "paper", "a4", "paper size: a4, a3 or letter")
+	flags.BoolVar(&opts.Landscape, "landscape", false, "turn the sheets sideways")
+	flags.StringVar(&opts.Grid, "grid", "", "`CxR` columns and rows of cards per sheet, like 3x3, as many as fit by default")
+	flags.Float64Var(&opts.Margin, "margin", 10, "least space around the cards, in `mm`")
+	flags.Float64Var(&opts.Gutter, "gutter", 0, "space between the cards, in `mm`")
+	flags.Float64Var(&opts.Bleed, "bleed", 0, "extend the cards past their trim by `mm`, 3 is usual for print shops")
+	flags.BoolVar(&opts.CropMarks, "crop-marks", true, "draw crop marks in the margins")
+	flags.IntVar(&opts.Copies, "copies", 1, "copies of every card that doesn't set its own")
+	flags.StringVar(&opts.Back, "back", "", "image `file` printed behind every card, on alternate pages")
+	flags.StringVar(&opts.LegislationBack, "legislation-back", "", "image `file` printed behind legislation cards")
+	flags.StringVar(&opts.ActionBack, "action-back", "", "image `file` printed behind action cards")
+	flags.StringVar(&opts.Flip, "flip", sejm.FlipLongEdge, "edge the sheets are turned over on when printed on both sides: long or short")
+}
+
+func cmdPDF(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "pdf: no deck files given")
+		return exitUsage
+	}
+	if opts.Copies < 1 {
+		fmt.Fprintln(os.Stderr, "pdf: -copies must be at least 1")
+		return exitUsage
+	}
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+
+	output := opts.Output
+	if output == "" {
+		if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Critical error - create directory %s yourself\n", opts.OutDir)
+			return exitIO
+		}
+		output = filepath.Join(opts.OutDir, "cards.pdf")
+	}
+	return printDecks(opts, args, output)
+}
+
+func ttsFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Output, "o", "", "`directory` to write the export to, tts in the output directory by default")
+	flags.StringVar(&opts.Name, "name", "Sejm", "`name` of the saved object and its files")
+	flags.StringVar(&opts.BaseURL, "url", "", "`URL` the sheet images are uploaded to, for games with other players; local files by default")
+	flags.IntVar(&opts.SheetSize, "sheet-size", 4096, "largest width and height of the deck sheets, in `pixels`")
+	flags.IntVar(&opts.Copies, "copies", 1, "copies of every card that doesn't set its own")
+	flags.StringVar(&opts.Back, "back", "", "image `file` for the backs of every card, a plain back by default")
+	flags.StringVar(&opts.LegislationBack, "legislation-back", "", "image `file` for the backs of legislation cards")
+	flags.StringVar(&opts.ActionBack, "action-back", "", "image `file` for the backs of action cards")
+}
+
+func cmdTTS(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "tts: no deck files given")
+		return exitUsage
+	}
+	if opts.Copies < 1 {
+		fmt.Fprintln(os.Stderr, "tts: -copies must be at least 1")
+		return exitUsage
+	}
+	if opts.SheetSize < 512 {
+		fmt.Fprintln(os.Stderr, "tts: -sheet-size must be at least 512")
+		return exitUsage
+	}
+	if opts.Name == "" || strings.ContainsAny(opts.Name, `/\`) {
+		fmt.Fprintf(os.Stderr, "tts: -name %q can't be used as a file name\n", opts.Name)
+		return exitUsage
+	}
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+
+	dir := opts.Output
+	if dir == "" {
+		dir = filepath.Join(opts.OutDir, "tts")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Critical error - create directory %s yourself\n", dir)
+		return exitIO
+	}
+	return exportTTS(opts, args, dir)
+}
+
+func vassalFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Output, "o", "", "module `file` to write, <name>.vmod in the output directory by default")
+	flags.StringVar(&opts.Name, "name", "Sejm", "`name` of the module")
+	flags.IntVar(&opts.Players, "players", 4, "`number` of players, each with a hand")
+	flags.IntVar(&opts.CardWidth, "card-width", 280, "width of the cards on the table, in `pixels`")
+	flags.IntVar(&opts.Copies, "copies", 1, "copies of every card that doesn't set its own")
+	flags.StringVar(&opts.Back, "back", "", "image `file` for the backs of every card, a plain back by default")
+	flags.StringVar(&opts.LegislationBack, "legislation-back", "", "image `file` for the backs of legislation cards")
+	flags.StringVar(&opts.ActionBack, "action-back", "", "image `file` for the backs of action cards")
+}
+
+func cmdVassal(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "vassal: no deck files given")
+		return exitUsage
+	}
+	if opts.Copies < 1 {
+		fmt.Fprintln(os.Stderr, "vassal: -copies must be at least 1")
+		return exitUsage
+	}
+	if opts.Players < 1 {
+		fmt.Fprintln(os.Stderr, "vassal: -players must be at least 1")
+		return exitUsage
+	}
+	if opts.CardWidth < 50 || opts.CardWidth > layout.Width {
+		fmt.Fprintf(os.Stderr, "vassal: -card-width must be between 50 and %d\n", layout.Width)
+		return exitUsage
+	}
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+
+	output := opts.Output
+	if output == "" {
+		if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Critical error - create directory %s yourself\n", opts.OutDir)
+			return exitIO
+		}
+		output = filepath.Join(opts.OutDir, opts.Name+".vmod")
+	}
+	return exportVassal(opts, args, output)
+}
+
+func cmdAssets(opts *options, args []string) int {
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+	infos, err := assetSource.Assets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	for _, info := range infos {
+		source := info.Source
+		if len(info.Replaces) > 0 {
+			source += " (replaces " + strings.Join(info.Replaces, ", ") + ")"
+		}
+		fmt.Printf("%-32s %s\n", info.Name, source)
+	}
+	return exitOK
+}
+
+func cmdClean(opts *options, args []string) int {
+	removed, err := LoadBuildCache(opts.OutDir).Clean()
+	for _, path := range removed {
+		opts.printf(verbose, "Removed %s\n", path)
+	}
+	opts.printf(normal, "Removed %d rendered cards\n", len(removed))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return exitOK
+}
+
+func watchFlags(flags *flag.FlagSet, opts *options) {
+	flags.DurationVar(&opts.Interval, "interval", 500*time.Millisecond, "how often to look for changes")
+}
+
+func cmdWatch(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "watch: no deck files given")
+		return exitUsage
+	}
+	for _, path := range args {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+	}
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+	if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Critical error - create directory %s yourself\n", opts.OutDir)
+		return exitIO
+	}
+
+	watchDecks(opts, args, opts.Interval)
+	return exitOK
+}
+
+func statsFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.CSV, "csv", "", "also write the numbers to a CSV `file`")
+	flags.StringVar(&opts.HTML, "html", "", "also write a report with charts to an HTML `file`")
+	flags.IntVar(&opts.Copies, "copies", 1, "copies of every card that doesn't set its own")
+}
+
+func cmdStats(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "stats: no deck files given")
+		return exitUsage
+	}
+	if opts.Copies < 1 {
+		fmt.Fprintln(os.Stderr, "stats: -copies must be at least 1")
+		return exitUsage
+	}
+
+	cards, failed := loadDecks(args)
+	if failed {
+		fmt.Println("stats: not counting decks with missing cards")
+		return exitFailures
+	}
+	stats := deckStats(cards, opts.Copies)
+	if err := writeStatsTable(os.Stdout, stats); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	for _, output := range []struct {
+		path  string
+		write func(io.Writer, *sejm.Stats) error
+	}{{opts.CSV, writeStatsCSV}, {opts.HTML, writeStatsHTML}} {
+		if output.path == "" {
+			continue
+		}
+		if err := writeStatsFile(output.path, stats, output.write); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+		opts.printf(normal, "Wrote %s\n", output.path)
+	}
+	return exitOK
+}
+
+func affinityFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Output, "o", "", "heatmap PNG `file` to write, affinity.png in the output directory by default")
+	flags.StringVar(&opts.CSV, "csv", "", "CSV `file` of every pair of groups to write, affinity.csv in the output directory by default")
+	flags.Float64Var(&opts.Alike, "alike", 0.9, "list the groups with a similarity of at least `s`, from -1 to 1")
+	flags.IntVar(&opts.Copies, "copies", 1, "copies of every card that doesn't set its own")
+}
+
+func cmdAffinity(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "affinity: no deck files given")
+		return exitUsage
+	}
+	if opts.Copies < 1 {
+		fmt.Fprintln(os.Stderr, "affinity: -copies must be at least 1")
+		return exitUsage
+	}
+	if opts.Alike < -1 || opts.Alike > 1 {
+		fmt.Fprintln(os.Stderr, "affinity: -alike must be from -1 to 1")
+		return exitUsage
+	}
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+	csvPath, pngPath, err := affinityOutputs(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return exportAffinity(opts, args, csvPath, pngPath)
+}
+
+func simulateFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Model, "model", "", "JSON or YAML vote model `file` of seats, voting chances, influence and spending, over the defaults")
+	flags.IntVar(&opts.Runs, "games", 10000, "number of games to play")
+	flags.Int64Var(&opts.Seed, "seed", 1, "seed of the random votes, the same seed gives the same results")
+	flags.IntVar(&opts.Workers, "j", runtime.GOMAXPROCS(0), "number of games to play at the same time")
+	flags.IntVar(&opts.Copies, "copies", 1, "copies of every card that doesn't set its own, each put to the vote")
+	flags.StringVar(&opts.CSV, "csv", "", "also write the chance every card passes to a CSV `file`")
+}
+
+func cmdSimulate(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "simulate: no deck files given")
+		return exitUsage
+	}
+	if opts.Runs < 1 || opts.Copies < 1 {
+		fmt.Fprintln(os.Stderr, "simulate: -games and -copies must be at least 1")
+		return exitUsage
+	}
+	model := game.DefaultVoteModel()
+	if opts.Model != "" {
+		var err error
+		if model, err = game.LoadVoteModel(opts.Model); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+	return simulateDecks(opts, args, model)
+}
+
+func validateFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Rules, "rules", "", "JSON or YAML `file` of design rules and their severities, over the defaults")
+	flags.BoolVar(&opts.JSON, "json", false, "print the problems as JSON")
+	flags.BoolVar(&opts.Strict, "strict", false, "fail on warnings too")
+}
+
+func cmdValidate(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "validate: no deck files given")
+		return exitUsage
+	}
+
+	if !opts.checkAssets() {
+		return exitUsage
+	}
+	var rules *sejm.Rules
+	if opts.Rules != "" {
+		var err error
+		if rules, err = renderer.LoadRules(opts.Rules); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+	return validateDecks(opts, args, rules)
+}
+
+// runDecks processes each deck, prints what happened and a summary line,
+// and turns the outcome into an exit code. Successful cards are printed
+// with the given label from verbosity okLevel up.
+func runDecks(opts *options, paths []string, verb, okLabel string, okLevel int, process func(path string) DeckReport) int {
+	succeeded, skipped, failed := 0, 0, 0
+	missing := false
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			missing = true
+			continue
+		}
+
+		report := process(path)
+		for _, result := range report.Rendered {
+			opts.printf(okLevel, "%s %s\n", okLabel, result)
+		}
+		for _, result := range report.Skipped {
+			opts.printf(verbose, "Up to date %s\n", result)
+		}
+		for _, err := range report.Failed {
+			fmt.Printf("Failed %v\n", err)
+		}
+		succeeded += len(report.Rendered)
+		skipped += len(report.Skipped)
+		failed += len(report.Failed)
+	}
+
+	opts.printf(normal, "----------------------------------------------------\n")
+	if skipped > 0 {
+		fmt.Printf("%s %d cards, %d up to date, %d failed\n", verb, succeeded, skipped, failed)
+	} else {
+		fmt.Printf("%s %d cards, %d failed\n", verb, succeeded, failed)
+	}
+	switch {
+	case missing:
+		return exitIO
+	case failed > 0:
+		return exitFailures
+	}
+	return exitOK
+}
+
+func convertFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.To, "to", "yaml", "deck format to write: code, json or yaml")
+	flags.StringVar(&opts.Output, "o", "", "write to `file` instead of standard output")
+	flags.BoolVar(&opts.Named, "named", false, "write opinions and effects by name in card codes")
+}
+
+func fmtFlags(flags *flag.FlagSet, opts *options) {
+	flags.BoolVar(&opts.Named, "named", false, "write opinions and effects by name in card codes")
+	flags.BoolVar(&opts.List, "l", false, "only list the files that are not formatted")
+}
+
+func cmdConvert(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "convert: no deck files given")
+		return exitUsage
+	}
+
+	var cards []DeckCard
+	failed := false
+	for _, path := range args {
+		deck, errs := LoadDeck(path)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "Failed %v\n", err)
+			failed = true
+		}
+		cards = append(cards, deck...)
+	}
+	if failed {
+		fmt.Fprintln(os.Stderr, "convert: not writing a deck with missing cards")
+		return exitFailures
+	}
+
+	return writeOutput(opts.Output, func(w io.Writer) error {
+		return WriteDeck(w, cards, opts.To, opts.Named)
+	})
+}
+
+func cmdFmt(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "fmt: no deck files given")
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, path := range args {
+		formatted, errs := FormatDeck(path, opts.Named)
+		if len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Printf("Failed %v\n", err)
+			}
+			code = exitFailures
+			continue
+		}
+
+		current, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+		if bytes.Equal(current, formatted) {
+			continue
+		}
+		if opts.List {
+			fmt.Println(path)
+			continue
+		}
+		if err := writeFileAtomic(path, formatted); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+		opts.printf(normal, "Formatted %s\n", path)
+	}
+	return code
+}
+
+func decodeFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.To, "to", "code", "deck format to write: code, json or yaml")
+	flags.StringVar(&opts.Output, "o", "", "write to `file` instead of standard output")
+}
+
+func cmdDecode(opts *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "decode: no card images given")
+		return exitUsage
+	}
+
+	var cards []DeckCard
+	code := exitOK
+	for i, path := range args {
+		meta, err := readCardMetadataFile(path)
+		if err == nil {
+			var card interface{}
+			card, err = meta.Card(game)
+			if err == nil {
+				cards = append(cards, DeckCard{Line: i + 1, Card: card})
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			code = exitFailures
+			continue
+		}
+
+		if !meta.Verify() {
+			fmt.Fprintf(os.Stderr, "%s: warning: the stored hash doesn't match the card code\n", path)
+		}
+		if opts.Verbosity >= verbose {
+			fmt.Fprintf(os.Stderr, "%s: %s card made by generator %s, hash %s\n", path, meta.Kind, meta.Version, meta.Hash)
+		}
+	}
+
+	if len(cards) > 0 {
+		if result := writeOutput(opts.Output, func(w io.Writer) error {
+			return WriteDeck(w, cards, opts.To, false)
+		}); result != exitOK {
+			return result
+		}
+	}
+	return code
+}
+
+func readCardMetadataFile(path string) (sejm.CardMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return sejm.CardMetadata{}, err
+	}
+	defer file.Close()
+
+	return sejm.ReadCardMetadata(file)
+}
+
+func cmdSchema(opts *options, args []string) int {
+	if GameFile == "" {
+		os.Stdout.Write(deckSchema)
+		return exitOK
+	}
+	schema, err := gameSchema(game)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	os.Stdout.Write(schema)
+	return exitOK
+}
+
+func cmdLayout(opts *options, args []string) int {
+	os.Stdout.Write(sejm.DefaultLayoutFile)
+	return exitOK
+}
+
+func cmdGame(opts *options, args []string) int {
+	os.Stdout.Write(sejm.DefaultGameFile)
+	return exitOK
+}
+
+// writeOutput writes to the named file, or to standard output when name is
+// empty.
+func writeOutput(name string, write func(w io.Writer) error) int {
+	if name == "" {
+		if err := write(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+		return exitOK
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return exitOK
+}
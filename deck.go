@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"sejm_generator/sejm"
+)
+
+// A deck file holds one card code per line, prefixed with its type:
+//
+//	# comments and blank lines are ignored
+//	legislation: art>title>(1,2,2,-2,-2,0,0,0,0,-1)>(0,0,0,1,-2,0,1)>3
+//	action: art>title>description>table>trust>2>red text
+//
+// "L:" and "A:" are accepted as short forms of the type markers.
+
+type DeckEntry struct {
+	Line int
+	Kind sejm.CardKind
+	Code string
+}
+
+type DeckError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *DeckError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *DeckError) Unwrap() error {
+	return e.Err
+}
+
+func parseKind(marker string) (sejm.CardKind, bool) {
+	switch strings.ToLower(strings.TrimSpace(marker)) {
+	case "legislation", "l":
+		return sejm.KindLegislation, true
+	case "action", "a":
+		return sejm.KindAction, true
+	}
+	return "", false
+}
+
+// ReadDeck splits a deck file into entries. Lines it cannot classify are
+// returned as errors instead of stopping the read.
+func ReadDeck(r io.Reader, name string) ([]DeckEntry, []error) {
+	var entries []DeckEntry
+	var errs []error
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		marker, code, found := strings.Cut(line, ":")
+		kind, ok := parseKind(marker)
+		if !found || !ok {
+			errs = append(errs, &DeckError{name, lineNo, fmt.Errorf("missing card type, expected \"legislation:\" or \"action:\"")})
+			continue
+		}
+		entries = append(entries, DeckEntry{Line: lineNo, Kind: kind, Code: strings.TrimSpace(code)})
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, &DeckError{name, lineNo, err})
+	}
+	return entries, errs
+}
+
+// DeckCard is a parsed card together with the line it was defined on.
+type DeckCard struct {
+	Line   int
+	Card   interface{} // LegislationCard or ActionCard
+	Copies int         // to print, 0 when the deck doesn't say
+}
+
+type DeckReport struct {
+	Rendered []string
+	Skipped  []string // up to date according to the build cache
+	Failed   []error
+}
+
+func ReadDeckFile(path string) ([]DeckEntry, []error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer file.Close()
+
+	return ReadDeck(file, path)
+}
+
+// LoadDeck reads and parses a deck of card codes, or a JSON, YAML, CSV or
+// TSV deck when the file extension says so. Cards that fail to parse are returned
+// as errors and left out.
+func LoadDeck(path string) ([]DeckCard, []error) {
+	if format := structuredFormat(path); format != "" {
+		return LoadStructuredDeck(path)
+	}
+	if spreadsheetSeparator(path) != 0 {
+		return LoadSpreadsheetDeck(path)
+	}
+
+	entries, errs := ReadDeckFile(path)
+	var cards []DeckCard
+	for _, entry := range entries {
+		card, err := parseEntry(entry)
+		if err != nil {
+			errs = append(errs, &DeckError{path, entry.Line, err})
+			continue
+		}
+		cards = append(cards, DeckCard{Line: entry.Line, Card: card})
+	}
+	sortByLine(errs)
+	return cards, errs
+}
+
+// renderDeck draws every card of a deck into outDir with the given number
+// of workers, collecting failures per line so that one bad card doesn't
+// stop the rest. Cards the cache knows to be up to date are skipped; cache
+// may be nil.
+func renderDeck(path string, outDir string, cache *BuildCache, workers int) DeckReport {
+	output := func(card DeckCard) string {
+		return cardOutput(card.Card, outDir)
+	}
+	return processDeck(path, workers, output, func(card DeckCard) (string, bool, error) {
+		return renderCached(card.Card, outDir, cache)
+	})
+}
+
+type cardResult struct {
+	result  string
+	skipped bool
+	err     error
+}
+
+// processDeck runs process on every card of a deck, on up to workers
+// cards at a time. The report lists the cards in deck order whatever
+// order they finished in. Cards writing the same output as an earlier card
+// of the deck fail instead, as which of them ended up there would depend
+// on the order they finished in.
+func processDeck(path string, workers int, output func(DeckCard) string, process func(DeckCard) (result string, skipped bool, err error)) DeckReport {
+	var report DeckReport
+
+	loaded, errs := LoadDeck(path)
+	report.Failed = append(report.Failed, errs...)
+
+	var cards []DeckCard
+	outputs := map[string]int{} // deck line by output
+	for _, card := range loaded {
+		name := output(card)
+		if line, ok := outputs[name]; ok {
+			report.Failed = append(report.Failed, &DeckError{path, card.Line, fmt.Errorf("renders to %s like the card on line %d, give it art of its own", name, line)})
+			continue
+		}
+		outputs[name] = card.Line
+		cards = append(cards, card)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]cardResult, len(cards))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(cards); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result, skipped, err := process(cards[i])
+				results[i] = cardResult{result, skipped, err}
+			}
+		}()
+	}
+	for i := range cards {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, card := range cards {
+		switch result := results[i]; {
+		case result.err != nil:
+			report.Failed = append(report.Failed, &DeckError{path, card.Line, result.err})
+		case result.skipped:
+			report.Skipped = append(report.Skipped, result.result)
+		default:
+			report.Rendered = append(report.Rendered, result.result)
+		}
+	}
+
+	sortByLine(report.Failed)
+	return report
+}
+
+func sortByLine(errs []error) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errorLine(errs[i]) < errorLine(errs[j])
+	})
+}
+
+func errorLine(err error) int {
+	var deckErr *DeckError
+	if errors.As(err, &deckErr) {
+		return deckErr.Line
+	}
+	return 0
+}
+
+// parseEntry returns a LegislationCard or an ActionCard.
+func parseEntry(entry DeckEntry) (interface{}, error) {
+	return game.ParseCard(entry.Kind, entry.Code)
+}
+
+// renderCached renders a card unless the cache says its output is up to
+// date, and records the result in the cache.
+func renderCached(card interface{}, outDir string, cache *BuildCache) (string, bool, error) {
+	if cache == nil {
+		output, err := renderCard(card, outDir)
+		return output, false, err
+	}
+
+	output := cardOutput(card, outDir)
+	key := cache.Key(card)
+	if cache.UpToDate(output, key) {
+		return output, true, nil
+	}
+	output, err := renderCard(card, outDir)
+	if err != nil {
+		cache.Forget(output)
+		return output, false, err
+	}
+	cache.Record(output, key)
+	return output, false, nil
+}
+
+// cardArt returns the art file of a card.
+func cardArt(card interface{}) string {
+	switch card := card.(type) {
+	case sejm.LegislationCard:
+		return card.ArtPath
+	case sejm.ActionCard:
+		return card.ArtPath
+	}
+	return ""
+}
+
+// cardOutput returns the file a card is rendered to.
+func cardOutput(card interface{}, outDir string) string {
+	return filepath.Join(outDir, outputName(cardArt(card)))
+}
+
+func renderCard(card interface{}, outDir string) (string, error) {
+	output := cardOutput(card, outDir)
+	switch card := card.(type) {
+	case sejm.LegislationCard:
+		return output, drawLegislationCard(card, output)
+	case sejm.ActionCard:
+		return output, drawActionCard(card, output)
+	}
+	return "", fmt.Errorf("unknown card type %T", card)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"sejm_generator/sejm"
+)
+
+// chdirTemp moves into a new temporary directory for the rest of the test,
+// as the art of the cards in a deck is relative to the working directory.
+func chdirTemp(tb testing.TB) string {
+	dir := tb.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+// writeTestArt writes a plain picture to use as art.
+func writeTestArt(tb testing.TB, path string) {
+	img := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// smallLayout is the layout of the printed game at a tenth of its size,
+// for tests that draw many cards and don't look at them closely.
+const smallLayout = `
+width: 168
+height: 258
+templates: {legislation: print_card.png, action: action_printcard.png}
+font: sylfaen.ttf
+regions:
+  art_bleed: {x: 0, y: 0, width: 168, height: 99}
+  art: {x: 9, y: 9, width: 150, height: 90}
+  title: {x: 0, y: 111, width: 168, size: 13, measure_size: 16, color: "#ffffff", align: center, single_line_offset: 10}
+  description: {x: 15, y: 141, width: 168, height: 76, padding: 2, size: 8, measure_size: 11, color: "#000000", align: center}
+  red_text: {x: 35, y: 229, width: 168, height: 17, padding: 2, size: 8, measure_size: 11, color: "#ffffff", align: center}
+  stamps_for: {x: 87, y: 132, width: 30, height: 30, columns: 2, rows: 2, step_x: 39, step_y: 39}
+  stamps_against: {x: 12, y: 132, width: 30, height: 30, columns: 2, rows: 2, step_x: 39, step_y: 39}
+  effects: {x: 12, y: 204, width: 30, height: 34, step_x: 36, step_y: 5}
+  cost: {x: 10, y: 12}
+  ribbon: {x: 0, y: 220, width: 168, height: 258}
+  symbol: {x: 9, y: 220, width: 30, height: 30}
+`
+
+// useSmallLayout draws cards with smallLayout for the rest of the test.
+func useSmallLayout(tb testing.TB) {
+	small, err := sejm.ReadLayout(strings.NewReader(smallLayout))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer func(previous *sejm.Layout) {
+		tb.Cleanup(func() {
+			layout = previous
+			renderer = newRenderer()
+		})
+	}(layout)
+	layout = small
+	renderer = newRenderer()
+}
+
+// writeTestDeck writes a card code deck of cards legislation and action
+// cards, each with art of its own, to the working directory.
+func writeTestDeck(tb testing.TB, cards int) string {
+	var deck strings.Builder
+	for i := 0; i < cards; i++ {
+		art := fmt.Sprintf("art%d", i)
+		writeTestArt(tb, art+".png")
+		if i%2 == 0 {
+			fmt.Fprintf(&deck, "legislation:%s>Ustawa %d>2,1,0,-1,-2,0,1,0,0,-1>1,-2,0,0,3,0,-1>2\n", art, i)
+		} else {
+			fmt.Fprintf(&deck, "action:%s>Akcja %d>Zagłosuj jeszcze raz.>paperclip>cash>3>Tylko raz\n", art, i)
+		}
+	}
+	if err := os.WriteFile("deck.txt", []byte(deck.String()), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return "deck.txt"
+}
+
+func TestProcessDeckSameOutput(t *testing.T) {
+	chdirTemp(t)
+	deck := "legislation:a>Pierwsza>0,0,0,0,0,0,0,0,0,0>0,0,0,0,0,0,0>1\n" +
+		"action:b>Druga>d>none>cash>1\n" +
+		"action:a>Trzecia>d>none>cash>1\n"
+	if err := os.WriteFile("deck.txt", []byte(deck), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 4} {
+		var processed []int
+		var mu sync.Mutex
+		report := processDeck("deck.txt", workers, func(card DeckCard) string {
+			return cardOutput(card.Card, "out")
+		}, func(card DeckCard) (string, bool, error) {
+			mu.Lock()
+			processed = append(processed, card.Line)
+			mu.Unlock()
+			return cardOutput(card.Card, "out"), false, nil
+		})
+
+		sort.Ints(processed)
+		if fmt.Sprint(processed) != "[1 2]" {
+			t.Errorf("workers %d: processed lines %v, want [1 2]", workers, processed)
+		}
+		if len(report.Failed) != 1 || errorLine(report.Failed[0]) != 3 || !strings.Contains(report.Failed[0].Error(), "line 1") {
+			t.Errorf("workers %d: failed %v, want line 3 naming line 1", workers, report.Failed)
+		}
+	}
+}
+
+func benchmarkRenderDeck(b *testing.B, cards, workers int) {
+	chdirTemp(b)
+	deck := writeTestDeck(b, cards)
+	out := "out"
+	if err := os.Mkdir(out, 0755); err != nil {
+		b.Fatal(err)
+	}
+	defer func() { renderer = newRenderer() }()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Every run starts without decoded assets, as a run of render does
+		renderer = newRenderer()
+		report := renderDeck(deck, out, nil, workers)
+		if len(report.Failed) > 0 {
+			b.Fatal(report.Failed)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*cards), "ns/card")
+}
+
+// BenchmarkRenderDeckCard renders a deck of a single card, which decodes
+// every asset it draws.
+func BenchmarkRenderDeckCard(b *testing.B) {
+	benchmarkRenderDeck(b, 1, 1)
+}
+
+// BenchmarkRenderDeck renders a deck of eight cards sharing the assets of
+// one renderer, on a worker for every CPU.
+func BenchmarkRenderDeck(b *testing.B) {
+	benchmarkRenderDeck(b, 8, runtime.NumCPU())
+}
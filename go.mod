@@ -7,3 +7,5 @@ require (
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	golang.org/x/image v0.18.0
 )
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sejm_generator/sejm"
+)
+
+// WriteDeck writes cards as a card code deck ("code"), or as a JSON or
+// YAML deck.
+func WriteDeck(w io.Writer, cards []DeckCard, format string, named bool) error {
+	if format != "code" {
+		return WriteStructuredDeck(w, cards, format)
+	}
+
+	for _, card := range cards {
+		line, err := game.MarshalCard(card.Card, named)
+		if err != nil {
+			return fmt.Errorf("card on line %d: %v", card.Line, err)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatDeck returns the canonical form of a deck file. Card code decks
+// keep their comments and blank lines and have every card line rewritten,
+// JSON and YAML decks are written out again. Card codes with fields past
+// the ones their card reads are refused, as rewriting them would drop the
+// fields.
+func FormatDeck(path string, named bool) ([]byte, []error) {
+	if spreadsheetSeparator(path) != 0 {
+		return nil, []error{fmt.Errorf("%s: spreadsheet decks can't be formatted", path)}
+	}
+
+	cards, errs := LoadDeck(path)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var out bytes.Buffer
+	if format := structuredFormat(path); format != "" {
+		if err := WriteStructuredDeck(&out, cards, format); err != nil {
+			return nil, []error{err}
+		}
+		return out.Bytes(), nil
+	}
+
+	entries, errs := ReadDeckFile(path)
+	for _, entry := range entries {
+		if err := sejm.ExtraFields(entry.Kind, entry.Code); err != nil {
+			errs = append(errs, &DeckError{path, entry.Line, fmt.Errorf("column %d: %v, remove it to format the deck", err.Column, err)})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	lines := map[int]interface{}{}
+	for _, card := range cards {
+		lines[card.Line] = card.Card
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if card, ok := lines[lineNo]; ok {
+			line, err = game.MarshalCard(card, named)
+			if err != nil {
+				errs = append(errs, &DeckError{path, lineNo, err})
+			}
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out.Bytes(), nil
+}
+
+// writeFileAtomic replaces a file through a temporary file in the same
+// directory, so that readers never see it half written.
+func writeFileAtomic(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+	os.Chmod(temp.Name(), mode)
+	return os.Rename(temp.Name(), path)
+}
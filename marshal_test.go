@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFormatDeck(t *testing.T) {
+	chdirTemp(t)
+	deck := "# Talia\n" +
+		"legislation: a>T>(kat:++, soc:-)>(inflacja:+)>2  \n" +
+		"\n" +
+		"a:b>Akcja>Opis>none>Gotówka>1\n"
+	if err := os.WriteFile("deck.txt", []byte(deck), 0644); err != nil {
+		t.Fatal(err)
+	}
+	formatted, errs := FormatDeck("deck.txt", true)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	want := "# Talia\n" +
+		"legislation: a>T>(kat:+2,soc:-1)>(inflacja:+1)>2\n" +
+		"\n" +
+		"action: b>Akcja>Opis>none>cash>1\n"
+	if string(formatted) != want {
+		t.Errorf("formatted as\n%s\nwant\n%s", formatted, want)
+	}
+}
+
+func TestFormatDeckExtraFields(t *testing.T) {
+	chdirTemp(t)
+	deck := "legislation: a>T>()>()>2>stare pole\n" +
+		"action: b>Akcja>Opis>none>cash>1>Czerwony>x\n" +
+		"action: c>Akcja>Opis>none>cash>1>Czerwony\n"
+	if err := os.WriteFile("deck.txt", []byte(deck), 0644); err != nil {
+		t.Fatal(err)
+	}
+	formatted, errs := FormatDeck("deck.txt", false)
+	if formatted != nil || len(errs) != 2 {
+		t.Fatalf("FormatDeck = %q, %v, want two errors", formatted, errs)
+	}
+	for i, want := range []string{"deck.txt:1: column 13: code: \"stare pole\"", "deck.txt:2: column 35: code: \"x\""} {
+		if !strings.HasPrefix(errs[i].Error(), want) {
+			t.Errorf("error %v, want one starting with %s", errs[i], want)
+		}
+	}
+}
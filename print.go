@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nfnt/resize"
+
+	"sejm_generator/sejm"
+)
+
+// sheetConfig turns the print flags into the imposition of the cards of
+// the current layout.
+func (opts *options) sheetConfig() (sejm.SheetConfig, error) {
+	paper, ok := sejm.PaperSizes[strings.ToLower(opts.Paper)]
+	if !ok {
+		return sejm.SheetConfig{}, fmt.Errorf("unknown paper size %q, expected a4, a3 or letter", opts.Paper)
+	}
+	config := sejm.SheetConfig{
+		Paper:     paper,
+		Landscape: opts.Landscape,
+		Margin:    opts.Margin,
+		Gutter:    opts.Gutter,
+		Bleed:     opts.Bleed,
+		CropMarks: opts.CropMarks,
+		Duplex:    opts.Back != "" || opts.LegislationBack != "" || opts.ActionBack != "",
+		Flip:      opts.Flip,
+	}
+	config.CardWidth, config.CardHeight = layout.TrimSize()
+
+	if opts.Grid != "" {
+		columns, rows, found := strings.Cut(strings.ToLower(opts.Grid), "x")
+		var err error
+		if found {
+			config.Columns, err = strconv.Atoi(columns)
+			if err == nil {
+				config.Rows, err = strconv.Atoi(rows)
+			}
+		}
+		if !found || err != nil || config.Columns < 1 || config.Rows < 1 {
+			return sejm.SheetConfig{}, fmt.Errorf("grid %q is not columns x rows, like 3x3", opts.Grid)
+		}
+	}
+	return config, nil
+}
+
+// cardBacks reads the back images of the print flags by card type. -back
+// is used for the types without a back of their own. Types with the same
+// file share its image, so that it is written once.
+func (opts *options) cardBacks() (map[sejm.CardKind]image.Image, error) {
+	backs := map[sejm.CardKind]image.Image{}
+	images := map[string]image.Image{}
+	for kind, path := range map[sejm.CardKind]string{sejm.KindLegislation: opts.LegislationBack, sejm.KindAction: opts.ActionBack} {
+		if path == "" {
+			path = opts.Back
+		}
+		if path == "" {
+			continue
+		}
+		if images[path] == nil {
+			img, err := loadImage(path)
+			if err != nil {
+				return nil, err
+			}
+			images[path] = img
+		}
+		backs[kind] = images[path]
+	}
+	return backs, nil
+}
+
+// plainBackColor fills the backs of card types without a back image.
+var plainBackColor = color.RGBA{0x3a, 0x3a, 0x3a, 0xff}
+
+// cardBack returns a back image scaled to width x height on white, or a
+// plain back when back is nil.
+func cardBack(back image.Image, width, height int) *image.RGBA {
+	bounds := image.Rect(0, 0, width, height)
+	img := image.NewRGBA(bounds)
+	if back == nil {
+		draw.Draw(img, bounds, image.NewUniform(plainBackColor), image.Point{}, draw.Src)
+		return img
+	}
+	scaled := resize.Resize(uint(width), uint(height), back, resize.Lanczos3)
+	draw.Draw(img, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(img, bounds, scaled, scaled.Bounds().Min, draw.Over)
+	return img
+}
+
+func loadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", path, err)
+	}
+	return img, nil
+}
+
+// cardKind returns the type of a LegislationCard or an ActionCard.
+func cardKind(card interface{}) sejm.CardKind {
+	return sejm.NewCardMetadata(card, Version).Kind
+}
+
+// loadDecks reads the cards of every deck, reporting the cards that fail
+// to parse.
+func loadDecks(paths []string) (cards []DeckCard, failed bool) {
+	for _, path := range paths {
+		deck, errs := LoadDeck(path)
+		for _, err := range errs {
+			fmt.Printf("Failed %v\n", err)
+			failed = true
+		}
+		cards = append(cards, deck...)
+	}
+	return cards, failed
+}
+
+// printDecks draws the cards of the decks and lays them out on the sheets
+// of a PDF, written to output once every card is drawn.
+func printDecks(opts *options, paths []string, output string) int {
+	config, err := opts.sheetConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	backs, err := opts.cardBacks()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+
+	cards, failed := loadDecks(paths)
+	if failed {
+		fmt.Println("pdf: not printing decks with missing cards")
+		return exitFailures
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	defer os.Remove(temp.Name())
+
+	sheets, err := sejm.NewSheetWriter(temp, config)
+	if err != nil {
+		temp.Close()
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	columns, rows := sheets.Grid()
+	opts.printf(verbose, "Printing %dx%d cards per %s sheet\n", columns, rows, opts.Paper)
+
+	printed := 0
+	for _, card := range cards {
+		img, err := renderer.Render(context.Background(), card.Card)
+		if err != nil {
+			fmt.Printf("Failed %s: %v\n", cardArt(card.Card), err)
+			failed = true
+			continue
+		}
+		copies := card.Copies
+		if copies == 0 {
+			copies = opts.Copies
+		}
+		if err := sheets.Add(img, backs[cardKind(card.Card)], copies); err != nil {
+			temp.Close()
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+		opts.printf(verbose, "Placed %d of %s\n", copies, cardArt(card.Card))
+		printed += copies
+	}
+	if failed {
+		temp.Close()
+		fmt.Println("pdf: not printing decks with missing cards")
+		return exitFailures
+	}
+
+	err = sheets.Close()
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), output)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	fmt.Printf("Printed %d cards on %d pages to %s\n", printed, sheets.Pages(), output)
+	return exitOK
+}
@@ -0,0 +1,220 @@
+package sejm
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+)
+
+// Affinity measures how alike the groups vote on a deck's legislation.
+// Over the cards, CoSupport[i][j] counts those groups i and j both support
+// and CoOpposition[i][j] those they both oppose, while Conflict[i][j]
+// counts those one supports and the other opposes. A card counts with the
+// weaker of the two opinions, ExtraFor and ExtraAgainst counting 2 and
+// For and Against 1, so that a card both groups hold strongly counts
+// double. The diagonals are how strongly a group supports and opposes
+// cards at all.
+type Affinity struct {
+	game         *Game
+	CoSupport    [][]int
+	CoOpposition [][]int
+	Conflict     [][]int
+	Cards        int // legislation cards, counting copies
+}
+
+// NewAffinity returns the affinity of the groups of the game over no cards.
+func (g *Game) NewAffinity() *Affinity {
+	matrix := func() [][]int {
+		m := make([][]int, len(g.Groups))
+		for i := range m {
+			m[i] = make([]int, len(g.Groups))
+		}
+		return m
+	}
+	return &Affinity{game: g, CoSupport: matrix(), CoOpposition: matrix(), Conflict: matrix()}
+}
+
+// Add counts copies of a card. Only legislation cards that fit the game
+// are counted.
+func (a *Affinity) Add(card interface{}, copies int) {
+	legislation, ok := card.(LegislationCard)
+	if !ok || a.game.CheckCard(legislation) != nil {
+		return
+	}
+	a.Cards += copies
+	for i, first := range legislation.Opinions {
+		for j, second := range legislation.Opinions {
+			weight := min(opinionWeight(first), opinionWeight(second)) * copies
+			switch {
+			case first > Indifferent && second > Indifferent:
+				a.CoSupport[i][j] += weight
+			case first < Indifferent && second < Indifferent:
+				a.CoOpposition[i][j] += weight
+			case first != Indifferent && second != Indifferent:
+				a.Conflict[i][j] += weight
+			}
+		}
+	}
+}
+
+// opinionWeight is how much an opinion counts, whichever way it goes.
+func opinionWeight(opinion Opinion) int {
+	switch {
+	case opinion >= ExtraFor, opinion <= ExtraAgainst:
+		return 2
+	case opinion == Indifferent:
+		return 0
+	}
+	return 1
+}
+
+// Similarity scales how alike two groups vote to the range -1 to 1: the
+// cards they agree on less those they disagree on, over how strongly each
+// holds opinions. It is 1 when they hold the same opinion of every card,
+// -1 when they hold opposite ones and 0 when either has no opinions.
+func (a *Affinity) Similarity(i, j int) float64 {
+	norm := math.Sqrt(float64(a.strength(i)) * float64(a.strength(j)))
+	if norm == 0 {
+		return 0
+	}
+	return float64(a.CoSupport[i][j]+a.CoOpposition[i][j]-a.Conflict[i][j]) / norm
+}
+
+func (a *Affinity) strength(group int) int {
+	return a.CoSupport[group][group] + a.CoOpposition[group][group]
+}
+
+// AlikePair is two groups whose votes are hard to tell apart.
+type AlikePair struct {
+	First, Second int
+	Similarity    float64
+}
+
+// Alike returns the pairs of groups with a similarity of at least
+// threshold, most alike first.
+func (a *Affinity) Alike(threshold float64) []AlikePair {
+	var pairs []AlikePair
+	for i := range a.CoSupport {
+		for j := i + 1; j < len(a.CoSupport); j++ {
+			if similarity := a.Similarity(i, j); similarity >= threshold {
+				pairs = append(pairs, AlikePair{i, j, similarity})
+			}
+		}
+	}
+	for i := 1; i < len(pairs); i++ {
+		for j := i; j > 0 && pairs[j].Similarity > pairs[j-1].Similarity; j-- {
+			pairs[j], pairs[j-1] = pairs[j-1], pairs[j]
+		}
+	}
+	return pairs
+}
+
+// Colours of the affinity heatmap
+var (
+	heatmapAlike    = color.NRGBA{0x1b, 0x5e, 0x20, 0xff}
+	heatmapOpposite = color.NRGBA{0xb7, 0x1c, 0x1c, 0xff}
+	heatmapSelf     = color.NRGBA{0xbd, 0xbd, 0xbd, 0xff}
+	heatmapLine     = color.NRGBA{0xe0, 0xe0, 0xe0, 0xff}
+)
+
+// DrawAffinity draws the affinity as a heatmap of cells pixels square,
+// the groups labelled with their stamps along the top and the left. Cells
+// are coloured by Similarity, from red for groups voting against each
+// other through white to green for groups voting alike, and show it. A
+// scale runs along the bottom.
+func (r *Renderer) DrawAffinity(a *Affinity, cell int) (image.Image, error) {
+	if cell < 16 {
+		return nil, fmt.Errorf("affinity: cells of %d pixels are too small", cell)
+	}
+	n := len(a.CoSupport)
+	scaleHeight := cell / 2
+	width, height := (n+1)*cell, (n+1)*cell+scaleHeight+cell/2
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+
+	ttf, err := r.assets.font(r.layout.Font)
+	if err != nil {
+		return nil, fmt.Errorf("font: %v", err)
+	}
+	text := func(size float64, c color.Color, s string, x, y int) {
+		face := truetype.NewFace(ttf, &truetype.Options{Size: size})
+		context := freetype.NewContext()
+		context.SetFont(ttf)
+		context.SetFontSize(size)
+		context.SetClip(canvas.Bounds())
+		context.SetDst(canvas)
+		context.SetSrc(image.NewUniform(c))
+		context.DrawString(s, freetype.Pt(x-textWidth(face, s)/2, y+int(size)/3))
+	}
+
+	icon := cell * 4 / 5
+	margin := (cell - icon) / 2
+	for i, group := range r.game.Groups[:n] {
+		stamp, err := r.assets.image(group.Stamp, icon, icon)
+		if err != nil {
+			return nil, fmt.Errorf("stamp of %s: %v", group.ID, err)
+		}
+		top := image.Rect(0, 0, icon, icon).Add(image.Point{(i+1)*cell + margin, margin})
+		left := image.Rect(0, 0, icon, icon).Add(image.Point{margin, (i+1)*cell + margin})
+		draw.Draw(canvas, top, stamp, image.Point{}, draw.Over)
+		draw.Draw(canvas, left, stamp, image.Point{}, draw.Over)
+	}
+
+	size := float64(cell) / 4
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			background := heatmapSelf
+			similarity := a.Similarity(i, j)
+			if i != j {
+				background = heatmapColor(similarity)
+			}
+			rect := image.Rect((j+1)*cell, (i+1)*cell, (j+2)*cell, (i+2)*cell)
+			draw.Draw(canvas, rect, image.NewUniform(background), image.Point{}, draw.Src)
+			draw.Draw(canvas, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+1), image.NewUniform(heatmapLine), image.Point{}, draw.Src)
+			draw.Draw(canvas, image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+1, rect.Max.Y), image.NewUniform(heatmapLine), image.Point{}, draw.Src)
+
+			ink := color.Color(color.Black)
+			if i != j && math.Abs(similarity) > 0.6 {
+				ink = color.White
+			}
+			text(size, ink, fmt.Sprintf("%.2f", similarity), rect.Min.X+cell/2, rect.Min.Y+cell/2)
+		}
+	}
+
+	// The scale, from -1 to 1
+	top := (n+1)*cell + cell/4
+	left, right := cell, width-cell/2
+	for x := left; x < right; x++ {
+		similarity := 2*float64(x-left)/float64(right-left-1) - 1
+		draw.Draw(canvas, image.Rect(x, top, x+1, top+scaleHeight/2), image.NewUniform(heatmapColor(similarity)), image.Point{}, draw.Src)
+	}
+	for _, mark := range []struct {
+		similarity float64
+		label      string
+	}{{-1, "-1 against each other"}, {0, "0"}, {1, "1 alike"}} {
+		x := left + int((mark.similarity+1)/2*float64(right-left-1))
+		labelWidth := textWidth(truetype.NewFace(ttf, &truetype.Options{Size: size * 0.8}), mark.label)
+		x = min(max(x, labelWidth/2), width-labelWidth/2)
+		text(size*0.8, color.Black, mark.label, x, top+scaleHeight/2+cell/4)
+	}
+	return canvas, nil
+}
+
+// heatmapColor mixes white with the colour of alike or opposite groups by
+// how far similarity is from 0.
+func heatmapColor(similarity float64) color.NRGBA {
+	target := heatmapAlike
+	if similarity < 0 {
+		target, similarity = heatmapOpposite, -similarity
+	}
+	similarity = math.Min(similarity, 1)
+	mix := func(c uint8) uint8 {
+		return uint8(255 - similarity*float64(255-int(c)))
+	}
+	return color.NRGBA{mix(target.R), mix(target.G), mix(target.B), 0xff}
+}
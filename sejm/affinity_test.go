@@ -0,0 +1,55 @@
+package sejm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAffinity(t *testing.T) {
+	const kat, prg, soc, pzc = 0, 1, 2, 3
+	affinity := DefaultGame.NewAffinity()
+	affinity.Add(NewLegislationCard("a", "A", []Opinion{2, 2, 1, -2, 0, 0, 0, 0, 0, 0}, []int{0, 0, 0, 0, 0, 0, 0}, 1), 1)
+	affinity.Add(NewLegislationCard("b", "B", []Opinion{-2, -2, -1, 1, 0, 0, 0, 0, 0, 0}, []int{0, 0, 0, 0, 0, 0, 0}, 1), 2)
+	affinity.Add(NewActionCard("c", "C", "", NoSymbol, 0, "", ""), 1)
+
+	tests := []struct {
+		name   string
+		matrix [][]int
+		i, j   int
+		want   int
+	}{
+		{"both strongly for", affinity.CoSupport, kat, prg, 2},
+		{"strongly for and for", affinity.CoSupport, kat, soc, 1},
+		{"strongly for alone", affinity.CoSupport, kat, kat, 2},
+		{"both strongly against, 2 copies", affinity.CoOpposition, kat, prg, 4},
+		{"strongly against and against, 2 copies", affinity.CoOpposition, prg, soc, 2},
+		{"against each other", affinity.Conflict, kat, pzc, 2 + 2},
+		{"indifferent", affinity.CoSupport, kat, 4, 0},
+	}
+	for _, test := range tests {
+		if got := test.matrix[test.i][test.j]; got != test.want {
+			t.Errorf("%s: [%d][%d] = %d, want %d", test.name, test.i, test.j, got, test.want)
+		}
+	}
+	if affinity.Cards != 3 {
+		t.Errorf("Cards = %d, want 3", affinity.Cards)
+	}
+
+	similarities := []struct {
+		i, j int
+		want float64
+	}{
+		{kat, prg, 1},
+		{kat, kat, 1},
+		{kat, pzc, -4 / math.Sqrt(6*4)},
+		{kat, 4, 0},
+	}
+	for _, test := range similarities {
+		if got := affinity.Similarity(test.i, test.j); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Similarity(%d, %d) = %v, want %v", test.i, test.j, got, test.want)
+		}
+	}
+	if alike := affinity.Alike(0.99); len(alike) != 1 || alike[0].First != kat || alike[0].Second != prg {
+		t.Errorf("Alike(0.99) = %v, want kat and prg", alike)
+	}
+}
@@ -0,0 +1,155 @@
+package sejm
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"strings"
+	"sync"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"github.com/nfnt/resize"
+	"golang.org/x/image/font"
+)
+
+type assetKey struct {
+	name          string
+	width, height int // 0 keeps the size of the file
+}
+
+type imageEntry struct {
+	once sync.Once
+	img  image.Image
+	err  error
+}
+
+type fontEntry struct {
+	once sync.Once
+	font *truetype.Font
+	err  error
+}
+
+// assetCache holds decoded images, resized to the size they are drawn at,
+// and parsed fonts. Every entry is loaded once even when several cards ask
+// for it at the same time.
+type assetCache struct {
+	source fs.FS
+
+	mu     sync.Mutex
+	images map[assetKey]*imageEntry
+	fonts  map[string]*fontEntry
+}
+
+func newAssetCache(source fs.FS) *assetCache {
+	return &assetCache{
+		source: source,
+		images: map[assetKey]*imageEntry{},
+		fonts:  map[string]*fontEntry{},
+	}
+}
+
+func (cache *assetCache) image(name string, width, height int) (image.Image, error) {
+	key := assetKey{name, width, height}
+	cache.mu.Lock()
+	entry, ok := cache.images[key]
+	if !ok {
+		entry = &imageEntry{}
+		cache.images[key] = entry
+	}
+	cache.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.img, entry.err = cache.decode(name)
+		if entry.err == nil && width != 0 && height != 0 {
+			entry.img = resize.Resize(uint(width), uint(height), entry.img, resize.Lanczos3)
+		}
+	})
+	return entry.img, entry.err
+}
+
+func (cache *assetCache) decode(name string) (image.Image, error) {
+	file, err := cache.source.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", name, err)
+	}
+	return img, nil
+}
+
+func (cache *assetCache) font(name string) (*truetype.Font, error) {
+	cache.mu.Lock()
+	entry, ok := cache.fonts[name]
+	if !ok {
+		entry = &fontEntry{}
+		cache.fonts[name] = entry
+	}
+	cache.mu.Unlock()
+
+	entry.once.Do(func() {
+		data, err := fs.ReadFile(cache.source, name)
+		if err != nil {
+			entry.err = err
+			return
+		}
+		entry.font, entry.err = freetype.ParseFont(data)
+	})
+	return entry.font, entry.err
+}
+
+func (cache *assetCache) forget(names []string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for _, name := range names {
+		for key := range cache.images {
+			if key.name == name {
+				delete(cache.images, key)
+			}
+		}
+		delete(cache.fonts, name)
+	}
+}
+
+// splitTextIntoLines splits the input text into multiple lines such that each line fits within the maxWidth.
+func splitTextIntoLines(face font.Face, text string, maxWidth int) []string {
+	words := strings.Fields(text)
+	var lines []string
+	var currentLine string
+
+	for _, word := range words {
+		testLine := currentLine + " " + word
+		if textWidth(face, strings.TrimSpace(testLine)) > maxWidth {
+			if currentLine == "" {
+				// If the current line is empty, add the word to the line anyway (to prevent infinite loop)
+				currentLine = word
+			}
+			lines = append(lines, strings.TrimSpace(currentLine))
+			currentLine = word
+		} else {
+			currentLine = testLine
+		}
+	}
+	if currentLine != "" {
+		lines = append(lines, strings.TrimSpace(currentLine))
+	}
+
+	return lines
+}
+
+func textWidth(face font.Face, text string) int {
+	width := 0
+	for _, char := range text {
+		aw, ok := face.GlyphAdvance(rune(char))
+		if !ok {
+			continue
+		}
+		width += int(aw >> 6)
+	}
+	return width
+}
@@ -0,0 +1,238 @@
+// Package sejm holds the cards of the Sejm board game: their types, the
+// card codes they are written in, the Game defining the groups, indicators,
+// currencies and symbols they use, and the Renderer that draws them.
+package sejm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CardKind is the type of a card as written in decks and card images.
+type CardKind string
+
+const (
+	KindLegislation CardKind = "legislation"
+	KindAction      CardKind = "action"
+)
+
+type Opinion int
+
+const (
+	ExtraAgainst Opinion = iota - 2
+	Against
+	Indifferent
+	For
+	ExtraFor
+)
+
+type Cost struct {
+	Value    int
+	Currency Currency
+}
+
+// Currency is the id of a currency of the game.
+type Currency string
+
+// LegislationCard holds a value for every group and indicator of its game.
+// Opinions are from ExtraAgainst to ExtraFor.
+type LegislationCard struct {
+	ArtPath  string
+	Title    string
+	Opinions []Opinion
+	Effects  []int
+	Cost     Cost
+}
+
+// Symbol is the id of an action card symbol of the game.
+type Symbol string
+
+const NoSymbol Symbol = ""
+
+type ActionCard struct {
+	ArtPath     string
+	Title       string
+	Description string
+	Symbol      Symbol
+	Cost        Cost
+	RedText     string
+}
+
+// ParseLegislationInput parses the card code of a legislation card of
+// DefaultGame.
+func ParseLegislationInput(input string) (LegislationCard, error) {
+	return DefaultGame.ParseLegislationInput(input)
+}
+
+// ParseActionInput parses the card code of an action card of DefaultGame.
+func ParseActionInput(input string) (ActionCard, error) {
+	return DefaultGame.ParseActionInput(input)
+}
+
+func (g *Game) ParseLegislationInput(input string) (LegislationCard, error) {
+	inputParts := splitCode(input, ">")
+
+	// Basic validation of input parts length
+	if err := checkFieldCount(input, inputParts, 5); err != nil {
+		return LegislationCard{}, err
+	}
+
+	artPath := inputParts[0].text
+	title := inputParts[1].text
+	opinions, err := g.stringToOpinions(inputParts[2])
+	if err != nil {
+		return LegislationCard{}, err
+	}
+	effects, err := g.stringToEffects(inputParts[3])
+	if err != nil {
+		return LegislationCard{}, err
+	}
+	cost, err := parseCost(inputParts[4])
+	if err != nil {
+		return LegislationCard{}, err
+	}
+
+	return g.NewLegislationCard(artPath, title, opinions, effects, cost), nil
+}
+
+func (g *Game) ParseActionInput(input string) (ActionCard, error) {
+	inputParts := splitCode(input, ">")
+
+	// Basic validation of input parts length
+	if err := checkFieldCount(input, inputParts, 6); err != nil {
+		return ActionCard{}, err
+	}
+
+	artPath := inputParts[0].text
+	title := inputParts[1].text
+	description := inputParts[2].text
+	symbol, ok := g.Symbol(inputParts[3].text)
+	if !ok {
+		return ActionCard{}, &ParseError{"symbol", inputParts[3].text, inputParts[3].column, "is not one of " + listOr(g.SymbolIDs(), "none")}
+	}
+	var currency Currency
+	if inputParts[4].text != "" {
+		currency, ok = g.Currency(inputParts[4].text)
+		if !ok {
+			return ActionCard{}, &ParseError{"currency", inputParts[4].text, inputParts[4].column, "is not one of " + listOr(g.CurrencyIDs())}
+		}
+	}
+	cost, err := parseCost(inputParts[5])
+	if err != nil {
+		return ActionCard{}, err
+	}
+	if currency == "" && cost != 0 {
+		return ActionCard{}, &ParseError{"currency", "", inputParts[4].column, "is required when the cost is not 0"}
+	}
+	var redtext string
+	if len(inputParts) >= 7 {
+		redtext = inputParts[6].text
+	}
+
+	return NewActionCard(artPath, title, description, symbol, cost, currency, redtext), nil
+}
+
+func parseCost(field codeField) (int, error) {
+	field = trimField(field, " ")
+	cost, err := strconv.Atoi(field.text)
+	if err != nil {
+		return 0, &ParseError{"cost", field.text, field.column, "is not an integer"}
+	}
+	return cost, nil
+}
+
+func NewActionCard(artPath, title, description string, symbol Symbol, cost int, currency Currency, redtext string) ActionCard {
+	return ActionCard{
+		ArtPath:     artPath + ".png",
+		Title:       title,
+		Description: description,
+		Symbol:      symbol,
+		Cost: Cost{
+			Value:    cost,
+			Currency: currency,
+		},
+		RedText: redtext,
+	}
+}
+
+// NewLegislationCard makes a legislation card of DefaultGame.
+func NewLegislationCard(artPath string, title string, opinions []Opinion, effects []int, cost int) LegislationCard {
+	return DefaultGame.NewLegislationCard(artPath, title, opinions, effects, cost)
+}
+
+// NewLegislationCard makes a legislation card costing the legislation
+// currency of the game.
+func (g *Game) NewLegislationCard(artPath string, title string, opinions []Opinion, effects []int, cost int) LegislationCard {
+
+	return LegislationCard{
+		ArtPath:  artPath + ".png",
+		Title:    title,
+		Opinions: opinions,
+		Effects:  effects,
+		Cost: Cost{
+			Value:    cost,
+			Currency: g.LegislationCurrency,
+		},
+	}
+}
+
+func (g *Game) stringToEffects(field codeField) ([]int, error) {
+	return parseList(field, "effects", g.IndicatorIDs(), g.IndicatorIndex, "indicator")
+}
+
+func (g *Game) stringToOpinions(field codeField) ([]Opinion, error) {
+	values, err := parseList(field, "opinions", g.GroupIDs(), g.GroupIndex, "group")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Opinion, len(values))
+	for i, value := range values {
+		result[i] = Opinion(value)
+	}
+	return result, nil
+}
+
+// parseList reads either the positional form "(1,2,-2,...)" holding a value
+// for every name, or the named form "(kat:+1,soc:++)".
+func parseList(field codeField, name string, names []string, lookup func(string) int, what string) ([]int, error) {
+	// Remove the parentheses
+	list := trimField(trimField(field, " "), "()")
+
+	if strings.Contains(list.text, ":") || strings.TrimSpace(list.text) == "" {
+		return parseNamedList(list, name, names, lookup, what)
+	}
+
+	parts := splitCode(list.text, ",")
+	if len(parts) != len(names) {
+		return nil, &ParseError{name, field.text, field.column, fmt.Sprintf("has %d values, expected %d", len(parts), len(names))}
+	}
+
+	values := make([]int, len(names))
+	for i, part := range parts {
+		part = trimField(codeField{part.text, list.column + part.column - 1}, " ")
+		value, err := strconv.Atoi(part.text)
+		if err != nil {
+			return nil, &ParseError{fmt.Sprintf("%s[%d]", name, i), part.text, part.column, "is not an integer"}
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// ParseCard parses the card code of a card of DefaultGame.
+func ParseCard(kind CardKind, code string) (interface{}, error) {
+	return DefaultGame.ParseCard(kind, code)
+}
+
+// ParseCard parses the card code of a card of the given kind into a
+// LegislationCard or an ActionCard.
+func (g *Game) ParseCard(kind CardKind, code string) (interface{}, error) {
+	switch kind {
+	case KindLegislation:
+		return g.ParseLegislationInput(code)
+	case KindAction:
+		return g.ParseActionInput(code)
+	}
+	return nil, fmt.Errorf("unknown card type %q", kind)
+}
@@ -0,0 +1,120 @@
+package sejm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseLegislationInput(t *testing.T) {
+	card, err := ParseLegislationInput("ustawa>Ustawa o Sejmie>(2,1,0,-1,-2,0,0,1,0,-1)>(1,0,0,-2,0,0,3)>2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LegislationCard{
+		ArtPath:  "ustawa.png",
+		Title:    "Ustawa o Sejmie",
+		Opinions: []Opinion{2, 1, 0, -1, -2, 0, 0, 1, 0, -1},
+		Effects:  []int{1, 0, 0, -2, 0, 0, 3},
+		Cost:     Cost{2, "cash"},
+	}
+	if !reflect.DeepEqual(card, want) {
+		t.Errorf("got %#v, want %#v", card, want)
+	}
+}
+
+func TestParseActionInput(t *testing.T) {
+	tests := []struct {
+		code string
+		want ActionCard
+	}{
+		{"akcja>Akcja>Opis>paperclip>cash>3>Uwaga", ActionCard{"akcja.png", "Akcja", "Opis", "paperclip", Cost{3, "cash"}, "Uwaga"}},
+		{"akcja>Akcja>Opis>none>>0", ActionCard{"akcja.png", "Akcja", "Opis", NoSymbol, Cost{0, ""}, ""}},
+		{"akcja>Akcja>Opis>none>Gotówka>-2", ActionCard{"akcja.png", "Akcja", "Opis", NoSymbol, Cost{-2, "cash"}, ""}},
+		// Fields past the red text are ignored, validate warns about them
+		{"akcja>Akcja>Opis>none>cash>1>Uwaga>stare pole", ActionCard{"akcja.png", "Akcja", "Opis", NoSymbol, Cost{1, "cash"}, "Uwaga"}},
+	}
+	for _, test := range tests {
+		card, err := ParseActionInput(test.code)
+		if err != nil {
+			t.Errorf("ParseActionInput(%q): %v", test.code, err)
+			continue
+		}
+		if card != test.want {
+			t.Errorf("ParseActionInput(%q) = %+v, want %+v", test.code, card, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		kind   CardKind
+		code   string
+		field  string
+		value  string
+		column int
+	}{
+		{KindLegislation, "a>T>(0,0,0,0,0,0,0,0,0,0)>(0,0,0,0,0,0,0)", "code", "", 42},
+		{KindLegislation, "a>T>(0,0,0,0,0,0,0,0,0)>(0,0,0,0,0,0,0)>1", "opinions", "(0,0,0,0,0,0,0,0,0)", 5},
+		{KindLegislation, "a>T>(0,0,0,x,0,0,0,0,0,0)>(0,0,0,0,0,0,0)>1", "opinions[3]", "x", 12},
+		{KindLegislation, "a>T>(0, 0, 0, 0, 0, 0, 0, 0, 0, 0)>(0,0, y ,0,0,0,0)>1", "effects[2]", "y", 42},
+		{KindLegislation, "a>T>(0,0,0,0,0,0,0,0,0,0)>(0,0,0,0,0,0,0)> 1x", "cost", "1x", 44},
+		{KindAction, "a>T>D>none>cash", "code", "", 16},
+		{KindAction, "a>T>D>kapelusz>cash>1", "symbol", "kapelusz", 7},
+		{KindAction, "a>T>D>none>euro>1", "currency", "euro", 12},
+		{KindAction, "a>T>D>none>>1", "currency", "", 12},
+		{KindAction, "a>T>D>none>cash>dużo", "cost", "dużo", 17},
+		// Columns count characters, not bytes
+		{KindLegislation, "łódź>Żółć gęślą>(0,0,0,x,0,0,0,0,0,0)>(0,0,0,0,0,0,0)>1", "opinions[3]", "x", 24},
+		{KindLegislation, "łódź>T>(kat:żółw)>()>1", "opinions[kat]", "żółw", 13},
+		{KindLegislation, "żółw>T>()>()", "code", "", 13},
+		{KindAction, "a>Żółć>Opis ąę>kapelusz>cash>1", "symbol", "kapelusz", 16},
+	}
+	for _, test := range tests {
+		_, err := ParseCard(test.kind, test.code)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseCard(%q): error %v, want a ParseError", test.code, err)
+			continue
+		}
+		if parseErr.Field != test.field || parseErr.Value != test.value || parseErr.Column != test.column {
+			t.Errorf("ParseCard(%q): %s %q at column %d, want %s %q at column %d",
+				test.code, parseErr.Field, parseErr.Value, parseErr.Column, test.field, test.value, test.column)
+		}
+	}
+}
+
+func TestExtraFields(t *testing.T) {
+	tests := []struct {
+		kind   CardKind
+		code   string
+		value  string
+		column int
+	}{
+		{KindLegislation, "a>T>()>()>1", "", 0},
+		{KindLegislation, "a>T>()>()>1>stare>pola", "stare", 13},
+		{KindAction, "a>T>D>none>>0>Uwaga", "", 0},
+		{KindAction, "a>T>D>none>>0>Uwaga>x", "x", 21},
+		{KindLegislation, "ą>T>()>()>1>stare", "stare", 13},
+	}
+	for _, test := range tests {
+		err := ExtraFields(test.kind, test.code)
+		switch {
+		case test.column == 0 && err != nil:
+			t.Errorf("ExtraFields(%q) = %v, want nil", test.code, err)
+		case test.column != 0 && (err == nil || err.Value != test.value || err.Column != test.column):
+			t.Errorf("ExtraFields(%q) = %v, want %q at column %d", test.code, err, test.value, test.column)
+		}
+	}
+}
+
+func TestPointer(t *testing.T) {
+	err := &ParseError{Field: "cost", Value: "x", Column: 5}
+	if got, want := err.Pointer("a>b>x"), "a>b>x\n    ^"; got != want {
+		t.Errorf("Pointer = %q, want %q", got, want)
+	}
+	err.Column = 4
+	if got, want := err.Pointer("żó>x"), "żó>x\n   ^"; got != want {
+		t.Errorf("Pointer = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package sejm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Describe writes out what a card does with the display names of the
+// game, for places that show cards as text next to their image.
+func (g *Game) Describe(card interface{}) string {
+	if err := g.CheckCard(card); err != nil {
+		return ""
+	}
+
+	var lines []string
+	var cost Cost
+	switch card := card.(type) {
+	case LegislationCard:
+		var fors, againsts []string
+		for i, opinion := range card.Opinions {
+			name := g.Groups[i].Name
+			if opinion == ExtraFor || opinion == ExtraAgainst {
+				name += " (strongly)"
+			}
+			switch opinion {
+			case For, ExtraFor:
+				fors = append(fors, name)
+			case Against, ExtraAgainst:
+				againsts = append(againsts, name)
+			}
+		}
+		var effects []string
+		for i, effect := range card.Effects {
+			if effect != 0 {
+				effects = append(effects, fmt.Sprintf("%s %+d", g.Indicators[i].Name, effect))
+			}
+		}
+		for _, line := range [][2]string{
+			{"For", strings.Join(fors, ", ")},
+			{"Against", strings.Join(againsts, ", ")},
+			{"Effects", strings.Join(effects, ", ")},
+		} {
+			if line[1] != "" {
+				lines = append(lines, line[0]+": "+line[1])
+			}
+		}
+		cost = card.Cost
+	case ActionCard:
+		for _, text := range []string{card.Description, card.RedText} {
+			if text != "" {
+				lines = append(lines, text)
+			}
+		}
+		if card.Symbol != NoSymbol {
+			lines = append(lines, "Symbol: "+g.Symbols[lookupName(g.symbols, string(card.Symbol))].Name)
+		}
+		cost = card.Cost
+	}
+
+	if cost.Value != 0 {
+		lines = append(lines, fmt.Sprintf("Cost: %+d %s", cost.Value, g.Currencies[lookupName(g.currencies, string(cost.Currency))].Name))
+	}
+	return strings.Join(lines, "\n")
+}
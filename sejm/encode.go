@@ -0,0 +1,47 @@
+package sejm
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+// Encode writes a rendered card as a "png", a "jpeg" or a "webp". PNGs get
+// the card definition written into their metadata, the others can't hold
+// it.
+func Encode(w io.Writer, img image.Image, format string, meta CardMetadata) error {
+	switch format {
+	case "png":
+		return EncodePNG(w, img, meta)
+	case "jpeg":
+		return EncodeJPEG(w, img)
+	case "webp":
+		return EncodeWebP(w, img)
+	}
+	return fmt.Errorf("unknown image format %q, expected png, jpeg or webp", format)
+}
+
+// EncodeJPEG writes a rendered card as a JPEG of quality 95.
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 95})
+}
+
+// EncodePDF writes a rendered card as a PDF of one page the trim size of
+// the card, see Layout.TrimSize.
+func EncodePDF(w io.Writer, img image.Image, width, height float64) error {
+	sheets, err := NewSheetWriter(w, SheetConfig{
+		Paper:      PaperSize{width, height},
+		CardWidth:  width,
+		CardHeight: height,
+		Columns:    1,
+		Rows:       1,
+	})
+	if err != nil {
+		return err
+	}
+	if err := sheets.Add(img, nil, 1); err != nil {
+		return err
+	}
+	return sheets.Close()
+}
@@ -0,0 +1,106 @@
+package sejm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError describes a single bad field of a card code. Column is the
+// 1-based position of Value within the code, counted in characters.
+type ParseError struct {
+	Field  string
+	Value  string
+	Column int
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("%s: %q %s", e.Field, e.Value, e.Reason)
+}
+
+// Pointer renders the code with a caret under the bad column, for the REPL.
+func (e *ParseError) Pointer(code string) string {
+	column := e.Column
+	if column < 1 {
+		column = 1
+	}
+	return code + "\n" + strings.Repeat(" ", column-1) + "^"
+}
+
+// codeField is one '>' separated part of a card code with its column.
+type codeField struct {
+	text   string
+	column int
+}
+
+func splitCode(input string, sep string) []codeField {
+	var fields []codeField
+	column := 1
+	for _, part := range strings.Split(input, sep) {
+		fields = append(fields, codeField{part, column})
+		column += utf8.RuneCountInString(part) + utf8.RuneCountInString(sep)
+	}
+	return fields
+}
+
+// trimField strips cutset from both ends of a field, keeping its column
+// pointing at the first remaining character.
+func trimField(field codeField, cutset string) codeField {
+	trimmedLeft := strings.TrimLeft(field.text, cutset)
+	return codeField{
+		text:   strings.TrimRight(trimmedLeft, cutset),
+		column: field.column + utf8.RuneCountInString(field.text[:len(field.text)-len(trimmedLeft)]),
+	}
+}
+
+// checkFieldCount checks that a card code has at least min fields. Fields
+// past the ones a card reads are ignored, as they always were; see
+// ExtraFields.
+func checkFieldCount(input string, fields []codeField, min int) error {
+	if len(fields) < min {
+		return &ParseError{
+			Field:  "code",
+			Column: utf8.RuneCountInString(input) + 1,
+			Reason: fmt.Sprintf("expected at least %d fields separated by '>', got %d", min, len(fields)),
+		}
+	}
+	return nil
+}
+
+// codeFields is the most fields the card code of each kind has.
+var codeFields = map[CardKind]int{KindLegislation: 5, KindAction: 7}
+
+// ExtraFields returns a ParseError pointing at the first field of a card
+// code past the ones a card of the kind reads, or nil when there are none.
+// Parsing ignores such fields.
+func ExtraFields(kind CardKind, code string) *ParseError {
+	max, ok := codeFields[kind]
+	fields := splitCode(code, ">")
+	if !ok || len(fields) <= max {
+		return nil
+	}
+	return &ParseError{
+		Field:  "code",
+		Value:  fields[max].text,
+		Column: fields[max].column,
+		Reason: fmt.Sprintf("starts an extra field that is ignored, expected at most %d fields separated by '>'", max),
+	}
+}
+
+// ArtError is returned when the art of a card can't be opened or decoded.
+type ArtError struct {
+	Path string
+	Err  error
+}
+
+func (e *ArtError) Error() string {
+	return "art: " + e.Err.Error()
+}
+
+func (e *ArtError) Unwrap() error {
+	return e.Err
+}
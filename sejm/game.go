@@ -0,0 +1,329 @@
+package sejm
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultGameFile is the game definition of the printed game.
+//
+//go:embed game.yaml
+var DefaultGameFile []byte
+
+// DefaultGame is DefaultGameFile, read. The functions and methods that
+// don't take a Game, like ParseLegislationInput and the text methods of
+// the cards, use it.
+var DefaultGame = mustReadGame(DefaultGameFile)
+
+// Game is the vocabulary cards are written in. LegislationCard.Opinions
+// and LegislationCard.Effects hold a value for each of its groups and
+// indicators, in order.
+type Game struct {
+	Groups              []GroupDef     `yaml:"groups" json:"groups"`
+	Indicators          []IndicatorDef `yaml:"indicators" json:"indicators"`
+	Currencies          []CurrencyDef  `yaml:"currencies" json:"currencies"`
+	LegislationCurrency Currency       `yaml:"legislation_currency" json:"legislation_currency"`
+	Symbols             []SymbolDef    `yaml:"symbols" json:"symbols"`
+
+	// Lower case ids, names and aliases to positions, filled by Check
+	groups, indicators, currencies, symbols map[string]int
+}
+
+// GroupDef is a group voting on legislation.
+type GroupDef struct {
+	ID          string   `yaml:"id" json:"id"`
+	Name        string   `yaml:"name" json:"name"`
+	Aliases     []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	Stamp       string   `yaml:"stamp" json:"stamp"`               // drawn for For and Against
+	StrongStamp string   `yaml:"strong_stamp" json:"strong_stamp"` // drawn for ExtraFor and ExtraAgainst
+}
+
+// IndicatorDef is an indicator legislation changes.
+type IndicatorDef struct {
+	ID      string   `yaml:"id" json:"id"`
+	Name    string   `yaml:"name" json:"name"`
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	Plus    string   `yaml:"plus" json:"plus"`
+	Minus   string   `yaml:"minus" json:"minus"`
+}
+
+// CurrencyDef is a currency cards cost. Its cost badges are plusN.png and
+// minusN.png, N from 1 to 5, in the Icons directory.
+type CurrencyDef struct {
+	ID      Currency `yaml:"id" json:"id"`
+	Name    string   `yaml:"name" json:"name"`
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	Icons   string   `yaml:"icons" json:"icons"`
+}
+
+// SymbolDef is a symbol of action cards.
+type SymbolDef struct {
+	ID      Symbol   `yaml:"id" json:"id"`
+	Name    string   `yaml:"name" json:"name"`
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	Icon    string   `yaml:"icon" json:"icon"`
+}
+
+// LoadGame reads a JSON or YAML game definition file.
+func LoadGame(path string) (*Game, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	game, err := ReadGame(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return game, nil
+}
+
+// ReadGame reads a JSON or YAML game definition and checks it.
+func ReadGame(r io.Reader) (*Game, error) {
+	var game Game
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&game); err != nil {
+		return nil, fmt.Errorf("reading game definition: %v", err)
+	}
+	if err := game.Check(); err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
+func mustReadGame(data []byte) *Game {
+	game, err := ReadGame(bytes.NewReader(data))
+	if err != nil {
+		panic("default game: " + err.Error())
+	}
+	return game
+}
+
+// Check checks that ids, names and aliases are unique within their list
+// and can be written in card codes, and that every icon is named. ReadGame
+// calls it, games built in Go must be checked before they are used.
+func (g *Game) Check() error {
+	if len(g.Groups) == 0 {
+		return fmt.Errorf("groups: at least one group is required")
+	}
+
+	var err error
+	g.groups, err = nameIndex("groups", len(g.Groups), func(i int) (string, string, []string, []string) {
+		group := g.Groups[i]
+		return group.ID, group.Name, group.Aliases, []string{"stamp", group.Stamp, "strong_stamp", group.StrongStamp}
+	})
+	if err != nil {
+		return err
+	}
+	g.indicators, err = nameIndex("indicators", len(g.Indicators), func(i int) (string, string, []string, []string) {
+		indicator := g.Indicators[i]
+		return indicator.ID, indicator.Name, indicator.Aliases, []string{"plus", indicator.Plus, "minus", indicator.Minus}
+	})
+	if err != nil {
+		return err
+	}
+	g.currencies, err = nameIndex("currencies", len(g.Currencies), func(i int) (string, string, []string, []string) {
+		currency := g.Currencies[i]
+		return string(currency.ID), currency.Name, currency.Aliases, []string{"icons", currency.Icons}
+	})
+	if err != nil {
+		return err
+	}
+	g.symbols, err = nameIndex("symbols", len(g.Symbols), func(i int) (string, string, []string, []string) {
+		symbol := g.Symbols[i]
+		return string(symbol.ID), symbol.Name, symbol.Aliases, []string{"icon", symbol.Icon}
+	})
+	if err != nil {
+		return err
+	}
+	if _, ok := g.symbols["none"]; ok {
+		return fmt.Errorf("symbols: \"none\" is reserved for cards without a symbol")
+	}
+
+	if g.LegislationCurrency == "" {
+		return fmt.Errorf("legislation_currency: is required")
+	}
+	if _, ok := g.Currency(string(g.LegislationCurrency)); !ok {
+		return fmt.Errorf("legislation_currency: %q is not one of the currencies", g.LegislationCurrency)
+	}
+	return nil
+}
+
+// nameIndex maps the lower case ids, names and aliases of a list to their
+// positions. entry returns the id, name, aliases and icon fields of the
+// i-th item, icons as pairs of field name and asset name.
+func nameIndex(list string, count int, entry func(i int) (id, name string, aliases, icons []string)) (map[string]int, error) {
+	index := map[string]int{}
+	for i := 0; i < count; i++ {
+		id, name, aliases, icons := entry(i)
+		if id == "" {
+			return nil, fmt.Errorf("%s[%d].id: is required", list, i)
+		}
+		if strings.ContainsAny(id, " ,:()>\n") {
+			return nil, fmt.Errorf("%s[%d].id: %q can't hold spaces, ',', ':', '(', ')' or '>'", list, i, id)
+		}
+		for j := 0; j < len(icons); j += 2 {
+			if icons[j+1] == "" {
+				return nil, fmt.Errorf("%s.%s.%s: is required", list, id, icons[j])
+			}
+		}
+
+		for _, key := range append([]string{id, name}, aliases...) {
+			key = normalizeName(key)
+			if key == "" {
+				continue
+			}
+			if other, ok := index[key]; ok && other != i {
+				return nil, fmt.Errorf("%s.%s: %q is already used by another entry", list, id, key)
+			}
+			index[key] = i
+		}
+	}
+	return index, nil
+}
+
+// GroupIDs returns the ids of the groups, in the order of
+// LegislationCard.Opinions.
+func (g *Game) GroupIDs() []string {
+	ids := make([]string, len(g.Groups))
+	for i, group := range g.Groups {
+		ids[i] = group.ID
+	}
+	return ids
+}
+
+// IndicatorIDs returns the ids of the indicators, in the order of
+// LegislationCard.Effects.
+func (g *Game) IndicatorIDs() []string {
+	ids := make([]string, len(g.Indicators))
+	for i, indicator := range g.Indicators {
+		ids[i] = indicator.ID
+	}
+	return ids
+}
+
+func (g *Game) CurrencyIDs() []string {
+	ids := make([]string, len(g.Currencies))
+	for i, currency := range g.Currencies {
+		ids[i] = string(currency.ID)
+	}
+	return ids
+}
+
+func (g *Game) SymbolIDs() []string {
+	ids := make([]string, len(g.Symbols))
+	for i, symbol := range g.Symbols {
+		ids[i] = string(symbol.ID)
+	}
+	return ids
+}
+
+// GroupIndex returns the position of a group in LegislationCard.Opinions,
+// or -1 for an unknown name. Ids, display names and aliases are accepted.
+func (g *Game) GroupIndex(name string) int {
+	return lookupName(g.groups, name)
+}
+
+// IndicatorIndex returns the position of an indicator in
+// LegislationCard.Effects, or -1 for an unknown name.
+func (g *Game) IndicatorIndex(name string) int {
+	return lookupName(g.indicators, name)
+}
+
+// Currency returns the id of the named currency.
+func (g *Game) Currency(name string) (Currency, bool) {
+	if i := lookupName(g.currencies, name); i >= 0 {
+		return g.Currencies[i].ID, true
+	}
+	return "", false
+}
+
+// Symbol returns the id of the named symbol. "none" and "" are NoSymbol.
+func (g *Game) Symbol(name string) (Symbol, bool) {
+	switch normalizeName(name) {
+	case "", "none":
+		return NoSymbol, true
+	}
+	if i := lookupName(g.symbols, name); i >= 0 {
+		return g.Symbols[i].ID, true
+	}
+	return "", false
+}
+
+// CheckCard reports a card that doesn't fit the game: lists of opinions
+// or effects of the wrong length, or an unknown currency or symbol.
+func (g *Game) CheckCard(card interface{}) error {
+	var cost Cost
+	switch card := card.(type) {
+	case LegislationCard:
+		if len(card.Opinions) != len(g.Groups) {
+			return fmt.Errorf("opinions: card has %d, the game has %d groups", len(card.Opinions), len(g.Groups))
+		}
+		if len(card.Effects) != len(g.Indicators) {
+			return fmt.Errorf("effects: card has %d, the game has %d indicators", len(card.Effects), len(g.Indicators))
+		}
+		cost = card.Cost
+	case ActionCard:
+		if _, ok := g.Symbol(string(card.Symbol)); !ok {
+			return fmt.Errorf("symbol: %q is not one of %s", card.Symbol, listOr(g.SymbolIDs(), "none"))
+		}
+		cost = card.Cost
+	default:
+		return fmt.Errorf("unknown card type %T", card)
+	}
+	if _, ok := g.Currency(string(cost.Currency)); !ok && cost.Value != 0 {
+		return fmt.Errorf("cost: %q is not one of %s", cost.Currency, listOr(g.CurrencyIDs()))
+	}
+	return nil
+}
+
+// stampAsset returns the stamp of a group, in its strong variant for
+// ExtraFor and ExtraAgainst.
+func (g *Game) stampAsset(id int, opinion Opinion) string {
+	if opinion == ExtraFor || opinion == ExtraAgainst {
+		return g.Groups[id].StrongStamp
+	}
+	return g.Groups[id].Stamp
+}
+
+// effectAsset returns the plus or minus icon of an indicator.
+func (g *Game) effectAsset(id int, value int) string {
+	if value > 0 {
+		return g.Indicators[id].Plus
+	}
+	return g.Indicators[id].Minus
+}
+
+// costAsset returns the badge of a cost. Values without a badge, beyond
+// the 5 every currency has, fail to draw rather than take another one's.
+func (g *Game) costAsset(cost Cost) string {
+	icons := g.Currencies[lookupName(g.currencies, string(cost.Currency))].Icons
+	badge := fmt.Sprintf("plus%d", cost.Value)
+	if cost.Value < 0 {
+		badge = fmt.Sprintf("minus%d", -cost.Value)
+	}
+	return path.Join(icons, badge+".png")
+}
+
+// symbolAsset returns the icon of an action card symbol.
+func (g *Game) symbolAsset(symbol Symbol) string {
+	return g.Symbols[lookupName(g.symbols, string(symbol))].Icon
+}
+
+// listOr joins names as "a, b or c".
+func listOr(names []string, more ...string) string {
+	names = append(append([]string{}, names...), more...)
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
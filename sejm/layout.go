@@ -0,0 +1,239 @@
+package sejm
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the regions of a layout
+const (
+	RegionArtBleed      = "art_bleed"
+	RegionArt           = "art"
+	RegionTitle         = "title"
+	RegionDescription   = "description"
+	RegionRedText       = "red_text"
+	RegionStampsFor     = "stamps_for"
+	RegionStampsAgainst = "stamps_against"
+	RegionEffects       = "effects"
+	RegionCost          = "cost"
+	RegionRibbon        = "ribbon"
+	RegionSymbol        = "symbol"
+)
+
+var (
+	imageRegions = []string{RegionArtBleed, RegionArt, RegionRibbon, RegionSymbol}
+	textRegions  = []string{RegionTitle, RegionDescription, RegionRedText}
+	gridRegions  = []string{RegionStampsFor, RegionStampsAgainst, RegionEffects}
+)
+
+// DefaultLayoutFile is the layout of the cards of the printed game.
+//
+//go:embed layout.yaml
+var DefaultLayoutFile []byte
+
+// DefaultLayout is DefaultLayoutFile, read.
+var DefaultLayout = mustReadLayout(DefaultLayoutFile)
+
+// Layout places everything drawn on a card. Sizes and positions are in
+// pixels of the rendered card.
+type Layout struct {
+	Width       int               `yaml:"width" json:"width"`
+	Height      int               `yaml:"height" json:"height"`
+	PixelsPerCM float64           `yaml:"pixels_per_cm,omitempty" json:"pixels_per_cm,omitempty"` // print resolution, 300 if 0
+	Templates   Templates         `yaml:"templates" json:"templates"`
+	Font        string            `yaml:"font" json:"font"` // asset name of the TrueType font of text regions
+	Regions     map[string]Region `yaml:"regions" json:"regions"`
+}
+
+// TrimSize returns the printed size of a card in millimetres.
+func (layout *Layout) TrimSize() (width, height float64) {
+	mm := layout.PixelsPerCM / 10
+	return float64(layout.Width) / mm, float64(layout.Height) / mm
+}
+
+// Templates are the asset names of the card backgrounds.
+type Templates struct {
+	Legislation string `yaml:"legislation" json:"legislation"`
+	Action      string `yaml:"action" json:"action"`
+}
+
+// Region is a named box of a layout. Images are resized to fill it, text
+// is aligned within it and grids of icons start in it.
+type Region struct {
+	X      int `yaml:"x" json:"x"`
+	Y      int `yaml:"y" json:"y"`
+	Width  int `yaml:"width,omitempty" json:"width,omitempty"`
+	Height int `yaml:"height,omitempty" json:"height,omitempty"`
+
+	// Grids repeat the region every StepX and StepY pixels, Columns and
+	// Rows times, or without limit when they are 0.
+	Columns int `yaml:"columns,omitempty" json:"columns,omitempty"`
+	Rows    int `yaml:"rows,omitempty" json:"rows,omitempty"`
+	StepX   int `yaml:"step_x,omitempty" json:"step_x,omitempty"`
+	StepY   int `yaml:"step_y,omitempty" json:"step_y,omitempty"`
+
+	// Text. Y is the baseline of the first line.
+	Font             string  `yaml:"font,omitempty" json:"font,omitempty"` // overrides the font of the layout
+	Size             float64 `yaml:"size,omitempty" json:"size,omitempty"`
+	MeasureSize      float64 `yaml:"measure_size,omitempty" json:"measure_size,omitempty"` // size text is aligned and wrapped at, Size if 0
+	LineHeight       int     `yaml:"line_height,omitempty" json:"line_height,omitempty"`   // Size if 0
+	Color            string  `yaml:"color,omitempty" json:"color,omitempty"`               // #rrggbb or #rrggbbaa
+	Align            string  `yaml:"align,omitempty" json:"align,omitempty"`               // left, center or right
+	Padding          int     `yaml:"padding,omitempty" json:"padding,omitempty"`
+	SingleLineOffset int     `yaml:"single_line_offset,omitempty" json:"single_line_offset,omitempty"`
+
+	color color.Color
+}
+
+// Rect returns the box of the region.
+func (region Region) Rect() image.Rectangle {
+	return image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height)
+}
+
+// Cell returns the box of the given column and row of a grid.
+func (region Region) Cell(column, row int) image.Rectangle {
+	return region.Rect().Add(image.Point{X: column * region.StepX, Y: row * region.StepY})
+}
+
+// Cells returns how many cells a grid has, or -1 when it has no limit.
+func (region Region) Cells() int {
+	if region.Columns == 0 || region.Rows == 0 {
+		return -1
+	}
+	return region.Columns * region.Rows
+}
+
+// LoadLayout reads a JSON or YAML layout file.
+func LoadLayout(path string) (*Layout, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	layout, err := ReadLayout(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return layout, nil
+}
+
+// ReadLayout reads a JSON or YAML layout and checks that it has every
+// region a card needs. Fields left out of a region are not filled in from
+// the default layout.
+func ReadLayout(r io.Reader) (*Layout, error) {
+	var layout Layout
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&layout); err != nil {
+		return nil, fmt.Errorf("reading layout: %v", err)
+	}
+	if err := layout.Check(); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+func mustReadLayout(data []byte) *Layout {
+	layout, err := ReadLayout(bytes.NewReader(data))
+	if err != nil {
+		panic("default layout: " + err.Error())
+	}
+	return layout
+}
+
+// Check checks that the layout has every region a card needs and fills in
+// the defaults of its text regions. ReadLayout calls it, layouts built in
+// Go must be checked before they are drawn with.
+func (layout *Layout) Check() error {
+	if layout.Width <= 0 || layout.Height <= 0 {
+		return fmt.Errorf("width and height: must be positive, got %dx%d", layout.Width, layout.Height)
+	}
+	switch {
+	case layout.PixelsPerCM == 0:
+		layout.PixelsPerCM = 300
+	case layout.PixelsPerCM < 0:
+		return fmt.Errorf("pixels_per_cm: must be positive, got %v", layout.PixelsPerCM)
+	}
+	if layout.Templates.Legislation == "" || layout.Templates.Action == "" {
+		return fmt.Errorf("templates: legislation and action are required")
+	}
+	if layout.Font == "" {
+		return fmt.Errorf("font: is required")
+	}
+
+	for _, name := range append(append(append([]string{RegionCost}, imageRegions...), textRegions...), gridRegions...) {
+		if _, ok := layout.Regions[name]; !ok {
+			return fmt.Errorf("regions.%s: is missing", name)
+		}
+	}
+	for _, name := range append(append([]string{}, imageRegions...), gridRegions...) {
+		if region := layout.Regions[name]; region.Width <= 0 || region.Height <= 0 {
+			return fmt.Errorf("regions.%s: width and height must be positive", name)
+		}
+	}
+	for _, name := range []string{RegionStampsFor, RegionStampsAgainst} {
+		if layout.Regions[name].Cells() < 0 {
+			return fmt.Errorf("regions.%s: columns and rows are required", name)
+		}
+	}
+
+	for _, name := range textRegions {
+		region := layout.Regions[name]
+		if region.Width <= 0 {
+			return fmt.Errorf("regions.%s.width: must be positive", name)
+		}
+		if region.Size <= 0 {
+			return fmt.Errorf("regions.%s.size: must be positive", name)
+		}
+		if region.MeasureSize == 0 {
+			region.MeasureSize = region.Size
+		}
+		if region.LineHeight == 0 {
+			region.LineHeight = int(region.Size)
+		}
+		if region.Font == "" {
+			region.Font = layout.Font
+		}
+		switch region.Align {
+		case "":
+			region.Align = "center"
+		case "left", "center", "right":
+		default:
+			return fmt.Errorf("regions.%s.align: %q is not left, center or right", name, region.Align)
+		}
+		c, err := parseColor(region.Color)
+		if err != nil {
+			return fmt.Errorf("regions.%s.color: %v", name, err)
+		}
+		region.color = c
+		layout.Regions[name] = region
+	}
+	return nil
+}
+
+// parseColor reads "#rrggbb" or "#rrggbbaa". No colour is black.
+func parseColor(text string) (color.Color, error) {
+	if text == "" {
+		return color.Black, nil
+	}
+	hex := strings.TrimPrefix(text, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if !strings.HasPrefix(text, "#") || len(hex) != 8 || err != nil {
+		return nil, fmt.Errorf("%q is not a colour like #ffffff", text)
+	}
+	// Colours are written unpremultiplied, as in CSS
+	return color.NRGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
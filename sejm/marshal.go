@@ -0,0 +1,193 @@
+package sejm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// artName undoes the ".png" NewLegislationCard and NewActionCard append,
+// giving back the file name used in card codes.
+func artName(artPath string) string {
+	return strings.TrimSuffix(artPath, ".png")
+}
+
+// String returns the canonical card code of the card, which
+// ParseLegislationInput reads back into the same card.
+func (card LegislationCard) String() string {
+	return card.code(nil)
+}
+
+// NamedString is like String but lists opinions and effects by their names
+// in DefaultGame and leaves out the zeros.
+func (card LegislationCard) NamedString() string {
+	return DefaultGame.NamedString(card)
+}
+
+// NamedString is like LegislationCard.String but lists opinions and effects
+// by the ids of the game and leaves out the zeros.
+func (g *Game) NamedString(card LegislationCard) string {
+	return card.code(g)
+}
+
+// code lists opinions and effects by name when the game naming them is
+// given.
+func (card LegislationCard) code(names *Game) string {
+	opinions := make([]int, len(card.Opinions))
+	for i, opinion := range card.Opinions {
+		opinions[i] = int(opinion)
+	}
+
+	var opinionList, effectList string
+	if names != nil {
+		opinionList = formatNamedList(opinions, names.GroupIDs())
+		effectList = formatNamedList(card.Effects, names.IndicatorIDs())
+	} else {
+		opinionList = formatList(opinions)
+		effectList = formatList(card.Effects)
+	}
+
+	return strings.Join([]string{
+		artName(card.ArtPath),
+		card.Title,
+		opinionList,
+		effectList,
+		strconv.Itoa(card.Cost.Value),
+	}, ">")
+}
+
+// MarshalText checks the card against DefaultGame.
+func (card LegislationCard) MarshalText() ([]byte, error) {
+	return DefaultGame.marshalLegislation(card, false)
+}
+
+func (g *Game) marshalLegislation(card LegislationCard, named bool) ([]byte, error) {
+	if err := checkCodeFields(map[string]string{"art": card.ArtPath, "title": card.Title}); err != nil {
+		return nil, err
+	}
+	if card.Cost.Currency != g.LegislationCurrency {
+		return nil, fmt.Errorf("cost: legislation cards can only cost %s, not %q", g.LegislationCurrency, card.Cost.Currency)
+	}
+	if err := g.CheckCard(card); err != nil {
+		return nil, err
+	}
+	if named {
+		return []byte(g.NamedString(card)), nil
+	}
+	return []byte(card.String()), nil
+}
+
+func (card *LegislationCard) UnmarshalText(text []byte) error {
+	parsed, err := ParseLegislationInput(string(text))
+	if err != nil {
+		return err
+	}
+	*card = parsed
+	return nil
+}
+
+// String returns the canonical card code of the card, which
+// ParseActionInput reads back into the same card.
+func (card ActionCard) String() string {
+	symbol := string(card.Symbol)
+	if card.Symbol == NoSymbol {
+		symbol = "none"
+	}
+
+	fields := []string{
+		artName(card.ArtPath),
+		card.Title,
+		card.Description,
+		symbol,
+		string(card.Cost.Currency),
+		strconv.Itoa(card.Cost.Value),
+	}
+	if card.RedText != "" {
+		fields = append(fields, card.RedText)
+	}
+	return strings.Join(fields, ">")
+}
+
+func (card ActionCard) MarshalText() ([]byte, error) {
+	err := checkCodeFields(map[string]string{
+		"art":         card.ArtPath,
+		"title":       card.Title,
+		"description": card.Description,
+		"red_text":    card.RedText,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(card.String()), nil
+}
+
+func (card *ActionCard) UnmarshalText(text []byte) error {
+	parsed, err := ParseActionInput(string(text))
+	if err != nil {
+		return err
+	}
+	*card = parsed
+	return nil
+}
+
+// checkCodeFields reports text that can't be written in a card code
+// because it would be read back as a field separator.
+func checkCodeFields(fields map[string]string) error {
+	for _, field := range []string{"art", "title", "description", "red_text"} {
+		value := fields[field]
+		if strings.ContainsAny(value, ">\n") {
+			return &ParseError{Field: field, Value: value, Reason: "contains '>' or a line break, which card codes can't hold"}
+		}
+	}
+	return nil
+}
+
+func formatList(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.Itoa(value)
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
+
+func formatNamedList(values []int, names []string) string {
+	var parts []string
+	for i, value := range values {
+		if value != 0 {
+			parts = append(parts, fmt.Sprintf("%s:%+d", names[i], value))
+		}
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
+
+// MarshalCard returns the deck line of a LegislationCard or an ActionCard
+// of DefaultGame, including its type marker.
+func MarshalCard(card interface{}, named bool) (string, error) {
+	return DefaultGame.MarshalCard(card, named)
+}
+
+// MarshalCard returns the deck line of a LegislationCard or an ActionCard,
+// including its type marker.
+func (g *Game) MarshalCard(card interface{}, named bool) (string, error) {
+	var text []byte
+	var err error
+	var kind CardKind
+
+	switch card := card.(type) {
+	case LegislationCard:
+		kind = KindLegislation
+		text, err = g.marshalLegislation(card, named)
+	case ActionCard:
+		kind = KindAction
+		text, err = card.MarshalText()
+		if err == nil {
+			err = g.CheckCard(card)
+		}
+	default:
+		return "", fmt.Errorf("unknown card type %T", card)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: %s", kind, text), nil
+}
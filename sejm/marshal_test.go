@@ -0,0 +1,80 @@
+package sejm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalCardRoundTrip(t *testing.T) {
+	cards := append([]interface{}{
+		NewActionCard("a", "Tytuł z ąę", "Opis, z przecinkiem (i nawiasem)", NoSymbol, 0, "", "Czerwony"),
+		NewLegislationCard("l", "Ujemny koszt", []Opinion{0, 0, 0, 0, 0, 0, 0, 0, 0, 2}, []int{0, 0, 0, 0, 0, 0, -3}, -4),
+	}, testDeck...)
+
+	for _, card := range cards {
+		for _, named := range []bool{false, true} {
+			line, err := MarshalCard(card, named)
+			if err != nil {
+				t.Errorf("MarshalCard(%v, %v): %v", card, named, err)
+				continue
+			}
+			marker, code, _ := strings.Cut(line, ": ")
+			parsed, err := ParseCard(CardKind(marker), code)
+			if err != nil {
+				t.Errorf("ParseCard(%q): %v", line, err)
+				continue
+			}
+			if !reflect.DeepEqual(parsed, card) {
+				t.Errorf("%q parsed as %#v, want %#v", line, parsed, card)
+			}
+
+			// The code written is canonical, so writing it again changes nothing
+			again, err := MarshalCard(parsed, named)
+			if err != nil || again != line {
+				t.Errorf("MarshalCard(ParseCard(%q)) = %q, %v", line, again, err)
+			}
+		}
+	}
+}
+
+func TestMarshalCardCanonical(t *testing.T) {
+	tests := []struct {
+		kind  CardKind
+		code  string
+		named bool
+		want  string
+	}{
+		{KindLegislation, "a>T>(kat:++, soc:-)>(inflacja:+)>2", false, "legislation: a>T>(2,0,-1,0,0,0,0,0,0,0)>(0,0,0,0,0,0,1)>2"},
+		{KindLegislation, "a>T>(2,0,-1,0,0,0,0,0,0,0)>(0,0,0,0,0,0,1)>2", true, "legislation: a>T>(kat:+2,soc:-1)>(inflacja:+1)>2"},
+		{KindAction, "a>T>D>none>Gotówka>1", false, "action: a>T>D>none>cash>1"},
+		{KindAction, "a>T>D>table>>0>", false, "action: a>T>D>table>>0"},
+	}
+	for _, test := range tests {
+		card, err := ParseCard(test.kind, test.code)
+		if err != nil {
+			t.Fatalf("ParseCard(%q): %v", test.code, err)
+		}
+		if got, err := MarshalCard(card, test.named); err != nil || got != test.want {
+			t.Errorf("MarshalCard(%q, %v) = %q, %v, want %q", test.code, test.named, got, err, test.want)
+		}
+	}
+}
+
+func TestMarshalCardErrors(t *testing.T) {
+	tests := []struct {
+		card interface{}
+		err  string
+	}{
+		{NewActionCard("a", "Więcej > mniej", "", NoSymbol, 0, "", ""), "title"},
+		{NewActionCard("a", "T", "Dwie\nlinie", NoSymbol, 0, "", ""), "description"},
+		{LegislationCard{ArtPath: "a.png", Title: "T", Opinions: make([]Opinion, 10), Effects: make([]int, 7), Cost: Cost{1, "trust"}}, "cost"},
+		{LegislationCard{ArtPath: "a.png", Title: "T", Opinions: make([]Opinion, 3), Effects: make([]int, 7), Cost: Cost{1, "cash"}}, "opinions"},
+		{"karta", "unknown card type"},
+	}
+	for _, test := range tests {
+		if _, err := MarshalCard(test.card, false); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("MarshalCard(%#v): error %v, want one about %s", test.card, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package sejm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// lookupName returns the position of a name in an index built by Check, or
+// -1.
+func lookupName(index map[string]int, name string) int {
+	if i, ok := index[normalizeName(name)]; ok {
+		return i
+	}
+	return -1
+}
+
+// ParseSignedValue reads "2", "+1", "-2" or the shorthand "+", "++", "-"
+// and "--" where each sign counts as one.
+func ParseSignedValue(value string) (int, bool) {
+	if value != "" && strings.Trim(value, "+") == "" {
+		return len(value), true
+	}
+	if value != "" && strings.Trim(value, "-") == "" {
+		return -len(value), true
+	}
+	number, err := strconv.Atoi(value)
+	return number, err == nil
+}
+
+// parseNamedList reads a sparse list like "(kat:+1,soc:++,nar:--)".
+// Entries that are not listed stay 0.
+func parseNamedList(list codeField, field string, names []string, lookup func(string) int, what string) ([]int, error) {
+	values := make([]int, len(names))
+	if strings.TrimSpace(list.text) == "" {
+		return values, nil
+	}
+
+	listed := make([]bool, len(names))
+	for _, part := range splitCode(list.text, ",") {
+		part = trimField(codeField{part.text, list.column + part.column - 1}, " ")
+		name, value, found := strings.Cut(part.text, ":")
+		name = strings.TrimSpace(name)
+		if !found {
+			return nil, &ParseError{field, part.text, part.column, "is not in the form name:value"}
+		}
+
+		idx := lookup(name)
+		if idx < 0 {
+			return nil, &ParseError{field, name, part.column, fmt.Sprintf("is not a %s, expected one of %s", what, strings.Join(names, ", "))}
+		}
+		entry := fmt.Sprintf("%s[%s]", field, names[idx])
+		if listed[idx] {
+			return nil, &ParseError{entry, name, part.column, "is listed twice"}
+		}
+		listed[idx] = true
+
+		value = strings.TrimLeft(value, " ")
+		valueColumn := part.column + utf8.RuneCountInString(part.text[:len(part.text)-len(value)])
+		value = strings.TrimSpace(value)
+		number, ok := ParseSignedValue(value)
+		if !ok {
+			return nil, &ParseError{entry, value, valueColumn, "is not a number or a run of + or -"}
+		}
+		values[idx] = number
+	}
+	return values, nil
+}
+
+func indexOf(list []string, value string) int {
+	for i, item := range list {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,89 @@
+package sejm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSignedValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+		ok    bool
+	}{
+		{"2", 2, true},
+		{"+1", 1, true},
+		{"-2", -2, true},
+		{"+", 1, true},
+		{"++", 2, true},
+		{"-", -1, true},
+		{"--", -2, true},
+		{"+-", 0, false},
+		{"", 0, false},
+		{"dwa", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseSignedValue(test.value)
+		if ok != test.ok || ok && got != test.want {
+			t.Errorf("ParseSignedValue(%q) = %d, %v, want %d, %v", test.value, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestNamedOpinionsAndEffects(t *testing.T) {
+	positional, err := ParseLegislationInput("a>T>(1,2,-2,0,0,-1,0,0,0,0)>(1,0,0,0,0,0,-2)>1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := []string{
+		"a>T>(kat:+1,prg:++,soc:--,nar:-)>(dochod:1,inflacja:-2)>1",
+		"a>T>( Katolicy : + , PROGRESYWIŚCI:2, socjalisci:-2, Narodowcy:-1 )>(Dochód:+,inflacja:--)>1",
+		"a>T>(nar:-,soc:--,prg:++,kat:+)>(inflacja:-2,dochod:+1)>1",
+	}
+	for _, code := range codes {
+		card, err := ParseLegislationInput(code)
+		if err != nil {
+			t.Errorf("ParseLegislationInput(%q): %v", code, err)
+			continue
+		}
+		if !reflect.DeepEqual(card, positional) {
+			t.Errorf("ParseLegislationInput(%q) = %v, want %v", code, card, positional)
+		}
+	}
+
+	empty, err := ParseLegislationInput("a>T>()>()>0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(empty.Opinions, make([]Opinion, 10)) || !reflect.DeepEqual(empty.Effects, make([]int, 7)) {
+		t.Errorf("empty lists parsed as %v and %v, want zeros", empty.Opinions, empty.Effects)
+	}
+}
+
+func TestNamedListErrors(t *testing.T) {
+	tests := []struct {
+		code   string
+		field  string
+		value  string
+		column int
+	}{
+		{"a>T>(kat:+1,kot:+1)>()>1", "opinions", "kot", 13},
+		{"a>T>(kat:+1,Katolicy:-1)>()>1", "opinions[kat]", "Katolicy", 13},
+		{"a>T>(kat:+1, soc:bardzo)>()>1", "opinions[soc]", "bardzo", 18},
+		{"a>T>(kat:+1,soc)>()>1", "opinions", "soc", 13},
+		{"a>T>()>(dochod:1,pensje:2)>1", "effects", "pensje", 18},
+	}
+	for _, test := range tests {
+		_, err := ParseLegislationInput(test.code)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseLegislationInput(%q): error %v, want a ParseError", test.code, err)
+			continue
+		}
+		if parseErr.Field != test.field || parseErr.Value != test.value || parseErr.Column != test.column {
+			t.Errorf("ParseLegislationInput(%q): %s %q at column %d, want %s %q at column %d",
+				test.code, parseErr.Field, parseErr.Value, parseErr.Column, test.field, test.value, test.column)
+		}
+	}
+}
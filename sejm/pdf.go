@@ -0,0 +1,115 @@
+package sejm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+// pdfWriter writes a PDF file object by object, as they are made, so that
+// the images of a deck never have to be held in memory together. Object
+// numbers are reserved up front for objects that are written last, like
+// the page tree.
+type pdfWriter struct {
+	w       io.Writer
+	offset  int64
+	offsets []int64 // by object number - 1, 0 until written
+	err     error
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	p := &pdfWriter{w: w}
+	// The binary comment tells transfer tools the file is not text
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return p
+}
+
+func (p *pdfWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.offset += int64(n)
+	p.err = err
+}
+
+func (p *pdfWriter) write(data []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(data)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// reserve returns the number of an object to be written later.
+func (p *pdfWriter) reserve() int {
+	p.offsets = append(p.offsets, 0)
+	return len(p.offsets)
+}
+
+// object writes an object with the given dictionary, or other value, and
+// returns its number.
+func (p *pdfWriter) object(value string) int {
+	id := p.reserve()
+	p.objectAt(id, value)
+	return id
+}
+
+func (p *pdfWriter) objectAt(id int, value string) {
+	p.offsets[id-1] = p.offset
+	p.printf("%d 0 obj\n%s\nendobj\n", id, value)
+}
+
+// stream writes a stream object. dict holds the entries of its dictionary
+// other than the length.
+func (p *pdfWriter) stream(dict string, data []byte) int {
+	id := p.reserve()
+	p.offsets[id-1] = p.offset
+	p.printf("%d 0 obj\n<< %s /Length %d >>\nstream\n", id, dict, len(data))
+	p.write(data)
+	p.printf("\nendstream\nendobj\n")
+	return id
+}
+
+// content writes a page content stream, compressed.
+func (p *pdfWriter) content(ops []byte) int {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(ops)
+	zw.Close()
+	return p.stream("/Filter /FlateDecode", compressed.Bytes())
+}
+
+// image writes img as a JPEG image XObject. Gray images would be encoded
+// with one component, so img must be in colour.
+func (p *pdfWriter) image(img image.Image) (int, error) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}); err != nil {
+		return 0, err
+	}
+	bounds := img.Bounds()
+	return p.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode",
+		bounds.Dx(), bounds.Dy()), encoded.Bytes()), nil
+}
+
+// close writes the cross-reference table and the trailer pointing at the
+// catalog.
+func (p *pdfWriter) close(catalog int) error {
+	for id, offset := range p.offsets {
+		if offset == 0 && p.err == nil {
+			return fmt.Errorf("pdf: object %d was reserved but never written", id+1)
+		}
+	}
+
+	xref := p.offset
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, offset := range p.offsets {
+		p.printf("%010d 00000 n \n", offset)
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, catalog, xref)
+	return p.err
+}
@@ -0,0 +1,185 @@
+package sejm
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pdfObjects checks the structure of a PDF and returns its objects by
+// number: every offset in the cross-reference table must point at its
+// object, and the trailer must point at the table.
+func pdfObjects(t *testing.T, data []byte) map[int][]byte {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("\n%%EOF\n")) {
+		t.Fatalf("no PDF header or end of file in %q...", data[:min(len(data), 20)])
+	}
+	trailer := regexp.MustCompile(`trailer\n<< /Size (\d+) /Root (\d+) 0 R >>\nstartxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if trailer == nil {
+		t.Fatalf("no trailer in %q", data[max(0, len(data)-100):])
+	}
+	size, _ := strconv.Atoi(string(trailer[1]))
+	xref, _ := strconv.Atoi(string(trailer[3]))
+	if xref >= len(data) || !bytes.HasPrefix(data[xref:], []byte(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", size))) {
+		t.Fatalf("startxref %d doesn't point at a table of %d objects", xref, size)
+	}
+
+	objects := map[int][]byte{}
+	entries := data[xref+len(fmt.Sprintf("xref\n0 %d\n", size))+20:]
+	for id := 1; id < size; id++ {
+		entry := string(entries[(id-1)*20 : id*20])
+		offset, err := strconv.Atoi(strings.TrimSuffix(entry, " 00000 n \n"))
+		if err != nil {
+			t.Fatalf("xref entry of object %d: %q", id, entry)
+		}
+		header := fmt.Sprintf("%d 0 obj\n", id)
+		if !bytes.HasPrefix(data[offset:], []byte(header)) {
+			t.Fatalf("xref offset %d of object %d points at %q", offset, id, data[offset:min(len(data), offset+20)])
+		}
+		object, _, ok := bytes.Cut(data[offset+len(header):], []byte("\nendobj\n"))
+		if !ok {
+			t.Fatalf("object %d doesn't end", id)
+		}
+		objects[id] = object
+	}
+	root, _ := strconv.Atoi(string(trailer[2]))
+	if !bytes.HasPrefix(objects[root], []byte("<< /Type /Catalog ")) {
+		t.Fatalf("root object %d is %q, not a catalog", root, objects[root])
+	}
+	return objects
+}
+
+// countPDFObjects returns how many of objects start with prefix.
+func countPDFObjects(objects map[int][]byte, prefix string) int {
+	n := 0
+	for _, object := range objects {
+		if bytes.HasPrefix(object, []byte(prefix)) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEncodePDF(t *testing.T) {
+	var b bytes.Buffer
+	if err := EncodePDF(&b, testImage(), 63, 88); err != nil {
+		t.Fatal(err)
+	}
+	objects := pdfObjects(t, b.Bytes())
+	if pages := countPDFObjects(objects, "<< /Type /Page "); pages != 1 {
+		t.Errorf("%d pages, want 1", pages)
+	}
+
+	var images int
+	for id, object := range objects {
+		if !bytes.HasPrefix(object, []byte("<< /Type /XObject /Subtype /Image ")) {
+			continue
+		}
+		images++
+		_, stream, _ := bytes.Cut(object, []byte("stream\n"))
+		img, err := jpeg.Decode(bytes.NewReader(stream))
+		if err != nil {
+			t.Errorf("image %d: %v", id, err)
+			continue
+		}
+		if img.Bounds().Size() != testImage().Bounds().Size() {
+			t.Errorf("image %d is %v, want %v", id, img.Bounds().Size(), testImage().Bounds().Size())
+		}
+	}
+	if images != 1 {
+		t.Errorf("%d images, want 1", images)
+	}
+}
+
+func TestSheetWriter(t *testing.T) {
+	front, other, back := testImage(), image.NewNRGBA(image.Rect(0, 0, 7, 5)), image.NewNRGBA(image.Rect(0, 0, 7, 5))
+	tests := []struct {
+		config        SheetConfig
+		columns, rows int
+		pages         int
+		images        int
+	}{
+		{SheetConfig{Paper: PaperSizes["a4"], CardWidth: 63, CardHeight: 88, Margin: 5}, 3, 3, 2, 2},
+		{SheetConfig{Paper: PaperSizes["a4"], CardWidth: 63, CardHeight: 88, Margin: 5, Landscape: true}, 4, 2, 2, 2},
+		{SheetConfig{Paper: PaperSizes["a4"], CardWidth: 63, CardHeight: 88, Columns: 2, Rows: 2, Bleed: 3, CropMarks: true}, 2, 2, 3, 2},
+		{SheetConfig{Paper: PaperSizes["a4"], CardWidth: 63, CardHeight: 88, Margin: 5, Duplex: true}, 3, 3, 4, 3},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		sheets, err := NewSheetWriter(&b, test.config)
+		if err != nil {
+			t.Errorf("%+v: %v", test.config, err)
+			continue
+		}
+		if columns, rows := sheets.Grid(); columns != test.columns || rows != test.rows {
+			t.Errorf("%+v: grid %dx%d, want %dx%d", test.config, columns, rows, test.columns, test.rows)
+		}
+		// 10 cards, the backs of which are all the same image
+		for _, card := range []struct {
+			front  image.Image
+			copies int
+		}{{front, 7}, {other, 0}, {other, 3}} {
+			if err := sheets.Add(card.front, back, card.copies); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := sheets.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		objects := pdfObjects(t, b.Bytes())
+		if sheets.Pages() != test.pages {
+			t.Errorf("%+v: Pages() = %d, want %d", test.config, sheets.Pages(), test.pages)
+		}
+		if pages := countPDFObjects(objects, "<< /Type /Page "); pages != test.pages {
+			t.Errorf("%+v: %d page objects, want %d", test.config, pages, test.pages)
+		}
+		if count := countPDFObjects(objects, "<< /Type /Pages /Kids ["); count != 1 {
+			t.Errorf("%+v: %d page trees, want 1", test.config, count)
+		}
+		if images := countPDFObjects(objects, "<< /Type /XObject /Subtype /Image "); images != test.images {
+			t.Errorf("%+v: %d images, want %d", test.config, images, test.images)
+		}
+	}
+}
+
+func TestSheetWriterErrors(t *testing.T) {
+	tests := []struct {
+		config SheetConfig
+		err    string
+	}{
+		{SheetConfig{Paper: PaperSizes["a4"]}, "card size"},
+		{SheetConfig{CardWidth: 63, CardHeight: 88}, "paper size"},
+		{SheetConfig{Paper: PaperSizes["a4"], CardWidth: 63, CardHeight: 88, Bleed: -1}, "negative"},
+		{SheetConfig{Paper: PaperSizes["a4"], CardWidth: 63, CardHeight: 88, Flip: "top"}, "flip"},
+		{SheetConfig{Paper: PaperSizes["a4"], CardWidth: 63, CardHeight: 88, Columns: 4}, "columns: 4 don't fit"},
+		{SheetConfig{Paper: PaperSizes["a4"], CardWidth: 300, CardHeight: 88}, "not even one card"},
+	}
+	for _, test := range tests {
+		if _, err := NewSheetWriter(&bytes.Buffer{}, test.config); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("NewSheetWriter(%+v): error %v, want one about %s", test.config, err, test.err)
+		}
+	}
+
+	sheets, err := NewSheetWriter(&bytes.Buffer{}, SheetConfig{Paper: PaperSizes["a4"], CardWidth: 63, CardHeight: 88})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sheets.Close(); err == nil {
+		t.Errorf("Close without cards: no error")
+	}
+}
+
+func TestPDFWriterReserved(t *testing.T) {
+	p := newPDFWriter(&bytes.Buffer{})
+	p.reserve()
+	catalog := p.object("<< /Type /Catalog >>")
+	if err := p.close(catalog); err == nil || !strings.Contains(err.Error(), "object 1") {
+		t.Errorf("close with an unwritten object: error %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package sejm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Keywords of the PNG text chunks holding the card definition
+const (
+	metaKeyCode     = "Sejm-Card-Code"
+	metaKeyKind     = "Sejm-Card-Type"
+	metaKeyVersion  = "Sejm-Generator-Version"
+	metaKeyHash     = "Sejm-Card-Hash"
+	metaKeySoftware = "Software"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// CardMetadata is the definition of a card stored in its generated PNG.
+// Hash is the SHA-256 of the deck line "<type>: <code>".
+type CardMetadata struct {
+	Kind    CardKind
+	Code    string
+	Version string
+	Hash    string
+}
+
+// NewCardMetadata describes a LegislationCard or an ActionCard rendered by
+// the given version of the generator.
+func NewCardMetadata(card interface{}, version string) CardMetadata {
+	meta := CardMetadata{Version: version}
+	switch card := card.(type) {
+	case LegislationCard:
+		meta.Kind, meta.Code = KindLegislation, card.String()
+	case ActionCard:
+		meta.Kind, meta.Code = KindAction, card.String()
+	}
+	meta.Hash = meta.computeHash()
+	return meta
+}
+
+func (meta CardMetadata) computeHash() string {
+	sum := sha256.Sum256([]byte(meta.Line()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Line returns the deck line of the card.
+func (meta CardMetadata) Line() string {
+	return fmt.Sprintf("%s: %s", meta.Kind, meta.Code)
+}
+
+// Verify reports whether the stored hash matches the stored code.
+func (meta CardMetadata) Verify() bool {
+	return meta.Hash == meta.computeHash()
+}
+
+// Card parses the stored code back into a LegislationCard or an ActionCard
+// of the given game, or of DefaultGame when it is nil.
+func (meta CardMetadata) Card(game *Game) (interface{}, error) {
+	if game == nil {
+		game = DefaultGame
+	}
+	return game.ParseCard(meta.Kind, meta.Code)
+}
+
+// EncodePNG encodes img as a PNG and adds the card definition as text
+// chunks right after the header chunk.
+func EncodePNG(w io.Writer, img image.Image, meta CardMetadata) error {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		return err
+	}
+	data := encoded.Bytes()
+
+	// The signature is followed by IHDR: length, type, 13 bytes and a CRC
+	headerEnd := len(pngSignature) + 4 + 4 + 13 + 4
+	if _, err := w.Write(data[:headerEnd]); err != nil {
+		return err
+	}
+
+	chunks := []struct {
+		typ  string
+		data []byte
+	}{
+		{"tEXt", textChunk(metaKeySoftware, "sejm_generator "+meta.Version)},
+		{"tEXt", textChunk(metaKeyKind, string(meta.Kind))},
+		{"iTXt", itxtChunk(metaKeyCode, meta.Code)},
+		{"tEXt", textChunk(metaKeyVersion, meta.Version)},
+		{"tEXt", textChunk(metaKeyHash, meta.Hash)},
+	}
+	for _, chunk := range chunks {
+		if err := writeChunk(w, chunk.typ, chunk.data); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(data[headerEnd:])
+	return err
+}
+
+func textChunk(keyword, text string) []byte {
+	return []byte(keyword + "\x00" + text)
+}
+
+// itxtChunk holds UTF-8 text, uncompressed and without a language tag.
+func itxtChunk(keyword, text string) []byte {
+	return []byte(keyword + "\x00\x00\x00\x00\x00" + text)
+}
+
+func writeChunk(w io.Writer, typ string, data []byte) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var footer [4]byte
+	binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+
+	for _, part := range [][]byte{header[:], data, footer[:]} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadCardMetadata reads the card definition from the text chunks of a
+// generated PNG.
+func ReadCardMetadata(r io.Reader) (CardMetadata, error) {
+	var meta CardMetadata
+
+	texts, err := readPNGText(r)
+	if err != nil {
+		return meta, err
+	}
+	meta.Kind = CardKind(texts[metaKeyKind])
+	meta.Code = texts[metaKeyCode]
+	meta.Version = texts[metaKeyVersion]
+	meta.Hash = texts[metaKeyHash]
+	if meta.Code == "" {
+		return meta, errors.New("no card definition found, the image was not made by sejm_generator or was re-saved by another program")
+	}
+	return meta, nil
+}
+
+// maxPNGText is the most bytes a text chunk read back may hold, inflated
+// or not. Card definitions are a line of a deck.
+const maxPNGText = 1 << 20
+
+// readPNGText returns the tEXt, zTXt and iTXt chunks of a PNG by keyword.
+func readPNGText(r io.Reader) (map[string]string, error) {
+	reader := bufio.NewReader(r)
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(reader, signature); err != nil || !bytes.Equal(signature, pngSignature) {
+		return nil, errors.New("not a PNG file")
+	}
+
+	texts := map[string]string{}
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return nil, fmt.Errorf("truncated PNG: %v", err)
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		typ := string(header[4:])
+
+		if typ == "IDAT" || typ == "IEND" {
+			// Text chunks written by the generator come before the image data
+			return texts, nil
+		}
+
+		if typ != "tEXt" && typ != "zTXt" && typ != "iTXt" {
+			// Skip the chunk data and CRC without trusting the length
+			if _, err := io.CopyN(io.Discard, reader, int64(length)+4); err != nil {
+				return nil, fmt.Errorf("truncated PNG: %v", err)
+			}
+			continue
+		}
+		if length > maxPNGText {
+			return nil, fmt.Errorf("%s chunk of %d bytes is too large, text chunks are at most %d", typ, length, maxPNGText)
+		}
+		data := make([]byte, length+4) // chunk data and CRC
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("truncated PNG: %v", err)
+		}
+		data = data[:length]
+
+		switch typ {
+		case "tEXt":
+			keyword, text, _ := bytes.Cut(data, []byte{0})
+			texts[string(keyword)] = latin1ToUTF8(text)
+		case "zTXt":
+			keyword, rest, _ := bytes.Cut(data, []byte{0})
+			if len(rest) > 0 {
+				if text, err := inflate(rest[1:]); err == nil {
+					texts[string(keyword)] = latin1ToUTF8(text)
+				}
+			}
+		case "iTXt":
+			keyword, text, err := parseITXt(data)
+			if err != nil {
+				return nil, err
+			}
+			texts[keyword] = text
+		}
+	}
+}
+
+func parseITXt(data []byte) (string, string, error) {
+	keyword, rest, _ := bytes.Cut(data, []byte{0})
+	if len(rest) < 2 {
+		return "", "", errors.New("malformed iTXt chunk")
+	}
+	compressed := rest[0] == 1
+	rest = rest[2:]
+	_, rest, _ = bytes.Cut(rest, []byte{0})  // language tag
+	_, text, _ := bytes.Cut(rest, []byte{0}) // translated keyword
+	if compressed {
+		inflated, err := inflate(text)
+		if err != nil {
+			return "", "", fmt.Errorf("malformed iTXt chunk: %v", err)
+		}
+		text = inflated
+	}
+	return string(keyword), string(text), nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	text, err := io.ReadAll(io.LimitReader(reader, maxPNGText+1))
+	if err == nil && len(text) > maxPNGText {
+		err = fmt.Errorf("text inflates to more than %d bytes", maxPNGText)
+	}
+	return text, err
+}
+
+func latin1ToUTF8(text []byte) string {
+	var b strings.Builder
+	for _, c := range text {
+		b.WriteRune(rune(c))
+	}
+	return b.String()
+}
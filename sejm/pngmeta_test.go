@@ -0,0 +1,98 @@
+package sejm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 7, 5))
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 7; x++ {
+			img.Set(x, y, color.NRGBA{uint8(30 * x), uint8(50 * y), 200, uint8(255 - 10*x)})
+		}
+	}
+	return img
+}
+
+func TestPNGMetadataRoundTrip(t *testing.T) {
+	for _, card := range testDeck {
+		meta := NewCardMetadata(card, "1.2.3")
+		var b bytes.Buffer
+		if err := EncodePNG(&b, testImage(), meta); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ReadCardMetadata(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatalf("ReadCardMetadata: %v", err)
+		}
+		if got != meta {
+			t.Errorf("ReadCardMetadata = %+v, want %+v", got, meta)
+		}
+		if !got.Verify() {
+			t.Errorf("Verify(%+v) = false", got)
+		}
+		parsed, err := got.Card(nil)
+		if err != nil {
+			t.Fatalf("Card: %v", err)
+		}
+		if NewCardMetadata(parsed, "1.2.3") != meta {
+			t.Errorf("Card() = %+v, want %+v", parsed, card)
+		}
+
+		// The text chunks must leave the image as it was
+		img, err := png.Decode(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatalf("png.Decode: %v", err)
+		}
+		if !bytes.Equal(img.(*image.NRGBA).Pix, testImage().Pix) {
+			t.Errorf("image changed by the metadata")
+		}
+	}
+}
+
+// pngWithChunk is a PNG signature and header followed by a chunk of the
+// given type, claimed length and data.
+func pngWithChunk(typ string, length uint32, data []byte) []byte {
+	var b bytes.Buffer
+	png.Encode(&b, testImage())
+	headerEnd := len(pngSignature) + 4 + 4 + 13 + 4
+	out := append([]byte{}, b.Bytes()[:headerEnd]...)
+	out = binary.BigEndian.AppendUint32(out, length)
+	out = append(out, typ...)
+	return append(out, data...)
+}
+
+func TestReadCardMetadataErrors(t *testing.T) {
+	var bomb bytes.Buffer
+	bomb.WriteString(metaKeyCode + "\x00\x01\x00\x00\x00")
+	writer := zlib.NewWriter(&bomb)
+	writer.Write(make([]byte, 2*maxPNGText))
+	writer.Close()
+
+	tests := []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{"not a PNG", []byte("GIF89a"), "not a PNG file"},
+		{"no metadata", pngWithChunk("IEND", 0, nil), "no card definition found"},
+		{"huge text chunk", pngWithChunk("tEXt", 1<<31, []byte("Software\x00x")), "too large"},
+		{"huge other chunk", pngWithChunk("gAMA", 1<<31, make([]byte, 100)), "truncated PNG"},
+		{"truncated chunk", pngWithChunk("tEXt", 100, []byte("Software\x00x")), "truncated PNG"},
+		{"text inflating too much", pngWithChunk("iTXt", uint32(bomb.Len()), append(bomb.Bytes(), 0, 0, 0, 0)), "inflates to more than"},
+	}
+	for _, test := range tests {
+		_, err := ReadCardMetadata(bytes.NewReader(test.file))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
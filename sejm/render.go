@@ -0,0 +1,445 @@
+package sejm
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"github.com/nfnt/resize"
+	"golang.org/x/image/font"
+
+	"sejm_generator/assets"
+)
+
+// ribbonAsset is drawn behind the red text of action cards
+const ribbonAsset = "ribbon.png"
+
+// Config sets up a Renderer.
+type Config struct {
+	// Assets holds the card templates, stamps, icons and the font, named
+	// as in the assets package. Nil uses the built-in assets.
+	Assets fs.FS
+
+	// OpenArt opens the art of a card by its ArtPath. Nil opens it as a
+	// file, relative to the working directory.
+	OpenArt func(path string) (io.ReadCloser, error)
+
+	// Layout places what is drawn on the cards, it must have passed
+	// Check. Nil uses DefaultLayout.
+	Layout *Layout
+
+	// Game names the icons of groups, indicators, currencies and symbols,
+	// it must have passed Check. Nil uses DefaultGame.
+	Game *Game
+}
+
+// Renderer draws cards layer by layer onto a single canvas. The assets it
+// draws are decoded and resized once and shared by every card it renders,
+// so a deck should be drawn with one Renderer. It is safe for concurrent
+// use.
+type Renderer struct {
+	assets  *assetCache
+	openArt func(path string) (io.ReadCloser, error)
+	layout  *Layout
+	game    *Game
+}
+
+func NewRenderer(config Config) *Renderer {
+	if config.Assets == nil {
+		config.Assets = assets.FS
+	}
+	r := &Renderer{
+		assets:  newAssetCache(config.Assets),
+		openArt: config.OpenArt,
+		layout:  config.Layout,
+		game:    config.Game,
+	}
+	if r.openArt == nil {
+		r.openArt = func(path string) (io.ReadCloser, error) {
+			return os.Open(path)
+		}
+	}
+	if r.layout == nil {
+		r.layout = DefaultLayout
+	}
+	if r.game == nil {
+		r.game = DefaultGame
+	}
+	return r
+}
+
+// Render draws a LegislationCard or an ActionCard.
+func (r *Renderer) Render(ctx context.Context, card interface{}) (image.Image, error) {
+	switch card := card.(type) {
+	case LegislationCard:
+		return r.RenderLegislation(ctx, card)
+	case ActionCard:
+		return r.RenderAction(ctx, card)
+	}
+	return nil, fmt.Errorf("unknown card type %T", card)
+}
+
+// Forget drops the named assets from the cache, so that they are read
+// again the next time a card draws them.
+func (r *Renderer) Forget(names ...string) {
+	r.assets.forget(names)
+}
+
+type layer func(canvas *image.RGBA) error
+
+func (r *Renderer) RenderLegislation(ctx context.Context, card LegislationCard) (image.Image, error) {
+	if err := r.game.CheckCard(card); err != nil {
+		return nil, err
+	}
+	return r.render(ctx, r.layout.Templates.Legislation, []layer{
+		func(canvas *image.RGBA) error { return r.drawArt(canvas, card.ArtPath) },
+		func(canvas *image.RGBA) error { return r.drawStamps(canvas, card.Opinions) },
+		func(canvas *image.RGBA) error { return r.drawEffects(canvas, card.Effects) },
+		func(canvas *image.RGBA) error { return r.drawCost(canvas, card.Cost) },
+		func(canvas *image.RGBA) error { return r.drawTitle(canvas, card.Title) },
+	})
+}
+
+func (r *Renderer) RenderAction(ctx context.Context, card ActionCard) (image.Image, error) {
+	if err := r.game.CheckCard(card); err != nil {
+		return nil, err
+	}
+	return r.render(ctx, r.layout.Templates.Action, []layer{
+		func(canvas *image.RGBA) error { return r.drawArt(canvas, card.ArtPath) },
+		func(canvas *image.RGBA) error { return r.drawTitle(canvas, card.Title) },
+		func(canvas *image.RGBA) error {
+			return r.drawText(canvas, card.Description, r.layout.Regions[RegionDescription])
+		},
+		func(canvas *image.RGBA) error {
+			if card.RedText == "" {
+				return nil
+			}
+			if err := r.drawRibbon(canvas); err != nil {
+				return err
+			}
+			return r.drawText(canvas, card.RedText, r.layout.Regions[RegionRedText])
+		},
+		func(canvas *image.RGBA) error { return r.drawSymbol(canvas, card.Symbol) },
+		func(canvas *image.RGBA) error { return r.drawCost(canvas, card.Cost) },
+	})
+}
+
+// render draws the layers in order onto the template, giving up between
+// layers once ctx is done.
+func (r *Renderer) render(ctx context.Context, template string, layers []layer) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	background, err := r.assets.image(template, r.layout.Width, r.layout.Height)
+	if err != nil {
+		return nil, fmt.Errorf("template: %v", err)
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, r.layout.Width, r.layout.Height))
+	draw.Draw(canvas, canvas.Bounds(), background, image.Point{}, draw.Src)
+
+	for _, drawLayer := range layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := drawLayer(canvas); err != nil {
+			return nil, err
+		}
+	}
+	return canvas, nil
+}
+
+// drawImage draws an asset resized to fill rect.
+func (r *Renderer) drawImage(canvas *image.RGBA, name string, rect image.Rectangle) error {
+	img, err := r.assets.image(name, rect.Dx(), rect.Dy())
+	if err != nil {
+		return err
+	}
+	draw.Draw(canvas, rect, img, image.Point{}, draw.Over)
+	return nil
+}
+
+// drawArt fills the top of the card with the art, then draws it again,
+// smaller, inside the frame.
+func (r *Renderer) drawArt(canvas *image.RGBA, artPath string) error {
+	file, err := r.openArt(artPath)
+	if err != nil {
+		return &ArtError{artPath, err}
+	}
+	art, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return &ArtError{artPath, fmt.Errorf("decoding %s: %w", artPath, err)}
+	}
+
+	bleed, frame := r.layout.Regions[RegionArtBleed].Rect(), r.layout.Regions[RegionArt].Rect()
+	art = resize.Resize(uint(bleed.Dx()), uint(bleed.Dy()), art, resize.Lanczos3)
+	draw.Draw(canvas, bleed, art, image.Point{}, draw.Over)
+
+	art = resize.Resize(uint(frame.Dx()), uint(frame.Dy()), art, resize.Lanczos3)
+	draw.Draw(canvas, frame, art, image.Point{}, draw.Over)
+	return nil
+}
+
+type group struct {
+	id      int
+	opinion Opinion
+}
+
+func (r *Renderer) drawStamps(canvas *image.RGBA, opinions []Opinion) error {
+	fors := make([]group, 0)
+	againsts := make([]group, 0)
+	for idx, op := range opinions {
+		if op == For || op == ExtraFor {
+			fors = append(fors, group{idx, op})
+		} else if op == Against || op == ExtraAgainst {
+			againsts = append(againsts, group{idx, op})
+		}
+	}
+
+	forStamps, againstStamps := r.layout.Regions[RegionStampsFor], r.layout.Regions[RegionStampsAgainst]
+	if len(fors) > forStamps.Cells() {
+		return fmt.Errorf("too many groups for, pick up to %d", forStamps.Cells())
+	}
+	if len(againsts) > againstStamps.Cells() {
+		return fmt.Errorf("too many groups against, pick up to %d", againstStamps.Cells())
+	}
+
+	if err := r.drawStampRow(canvas, fors, forStamps); err != nil {
+		return err
+	}
+	return r.drawStampRow(canvas, againsts, againstStamps)
+}
+
+// drawStampRow fills the grid of a region with stamps, row by row.
+func (r *Renderer) drawStampRow(canvas *image.RGBA, groups []group, grid Region) error {
+	for idx, group := range groups {
+		cell := grid.Cell(idx%grid.Columns, idx/grid.Columns)
+		if err := r.drawImage(canvas, r.game.stampAsset(group.id, group.opinion), cell); err != nil {
+			return fmt.Errorf("stamp of %s: %v", r.game.Groups[group.id].ID, err)
+		}
+	}
+	return nil
+}
+
+// drawEffects draws a column of icons for every non-zero effect, one icon
+// per point.
+func (r *Renderer) drawEffects(canvas *image.RGBA, effects []int) error {
+	grid := r.layout.Regions[RegionEffects]
+	column := 0
+	for idx, val := range effects {
+		if val == 0 {
+			continue
+		}
+		count := val
+		if count < 0 {
+			count = -count
+		}
+		if grid.Columns > 0 && column >= grid.Columns {
+			return fmt.Errorf("too many effects, pick up to %d", grid.Columns)
+		}
+		if grid.Rows > 0 && count > grid.Rows {
+			return fmt.Errorf("effect on %s: %d is too many points, pick up to %d", r.game.Indicators[idx].ID, count, grid.Rows)
+		}
+		// The lowest icon is drawn first, so that the top one is whole
+		for i := count - 1; i >= 0; i-- {
+			if err := r.drawImage(canvas, r.game.effectAsset(idx, val), grid.Cell(column, i)); err != nil {
+				return fmt.Errorf("effect on %s: %v", r.game.Indicators[idx].ID, err)
+			}
+		}
+		column++
+	}
+	return nil
+}
+
+func (r *Renderer) drawSymbol(canvas *image.RGBA, symbol Symbol) error {
+	if symbol == NoSymbol {
+		return nil
+	}
+	if err := r.drawImage(canvas, r.game.symbolAsset(symbol), r.layout.Regions[RegionSymbol].Rect()); err != nil {
+		return fmt.Errorf("symbol: %v", err)
+	}
+	return nil
+}
+
+func (r *Renderer) drawRibbon(canvas *image.RGBA) error {
+	if err := r.drawImage(canvas, ribbonAsset, r.layout.Regions[RegionRibbon].Rect()); err != nil {
+		return fmt.Errorf("ribbon: %v", err)
+	}
+	return nil
+}
+
+func (r *Renderer) drawCost(canvas *image.RGBA, cost Cost) error {
+	if cost.Value == 0 {
+		return nil
+	}
+	costImage, err := r.assets.image(r.game.costAsset(cost), 0, 0)
+	if err != nil {
+		return fmt.Errorf("cost: %v", err)
+	}
+	costRegion := r.layout.Regions[RegionCost]
+	position := image.Point{X: costRegion.X, Y: costRegion.Y}
+	draw.Draw(canvas, costImage.Bounds().Add(position), costImage, image.Point{}, draw.Over)
+	return nil
+}
+
+// newText prepares drawing text in the given region onto the canvas.
+func (r *Renderer) newText(canvas *image.RGBA, region Region) (*freetype.Context, font.Face, error) {
+	ttf, face, err := r.measureFace(region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	context := freetype.NewContext()
+	context.SetFont(ttf)
+	context.SetFontSize(region.Size)
+	context.SetClip(canvas.Bounds())
+	context.SetDst(canvas)
+	context.SetSrc(image.NewUniform(region.color))
+	return context, face, nil
+}
+
+// measureFace returns the font of a text region and the face its text is
+// aligned and wrapped with.
+func (r *Renderer) measureFace(region Region) (*truetype.Font, font.Face, error) {
+	ttf, err := r.assets.font(region.Font)
+	if err != nil {
+		return nil, nil, fmt.Errorf("font: %v", err)
+	}
+	return ttf, truetype.NewFace(ttf, &truetype.Options{Size: region.MeasureSize}), nil
+}
+
+// lineX returns where a line of the given width starts in a text region.
+func lineX(region Region, lineWidth int) int {
+	switch region.Align {
+	case "left":
+		return region.X + region.Padding
+	case "right":
+		return region.X + region.Width - region.Padding - lineWidth
+	}
+	return region.X + (region.Width-lineWidth)/2
+}
+
+// drawTitle draws the lines of splitTitle, a single line moved down by the
+// SingleLineOffset of the region.
+func (r *Renderer) drawTitle(canvas *image.RGBA, title string) error {
+	region := r.layout.Regions[RegionTitle]
+	context, face, err := r.newText(canvas, region)
+	if err != nil {
+		return err
+	}
+
+	y := region.Y
+	lines := splitTitle(title)
+	if len(lines) == 1 {
+		y += region.SingleLineOffset
+	}
+	for _, line := range lines {
+		x := lineX(region, textWidth(face, line))
+		if _, err := context.DrawString(line, freetype.Pt(x, y)); err != nil {
+			return fmt.Errorf("title: %v", err)
+		}
+		y += region.LineHeight
+	}
+	return nil
+}
+
+// splitTitle splits titles longer than 11 bytes into two lines at the
+// space nearest the middle.
+func splitTitle(title string) []string {
+	if len(title) <= 11 {
+		return []string{title}
+	}
+	mid := len(title) / 2
+	left := strings.LastIndex(title[:mid], " ")
+	right := strings.Index(title[mid:], " ") + mid
+
+	if left == -1 {
+		left = 0
+	}
+	if right == -1 || right >= len(title) {
+		right = len(title)
+	}
+	if mid-left < right-mid {
+		mid = left
+	} else {
+		mid = right
+	}
+	title = title[:mid] + "\n" + title[mid+1:]
+	return strings.Split(title, "\n")
+}
+
+// drawText wraps text to the width of the region and draws it line by
+// line.
+func (r *Renderer) drawText(canvas *image.RGBA, text string, region Region) error {
+	context, face, err := r.newText(canvas, region)
+	if err != nil {
+		return err
+	}
+
+	y := region.Y
+	for _, line := range splitTextIntoLines(face, text, region.Width-2*region.Padding) {
+		x := lineX(region, textWidth(face, line))
+		if _, err := context.DrawString(line, freetype.Pt(x, y)); err != nil {
+			return err
+		}
+		y += region.LineHeight
+	}
+	return nil
+}
+
+// CardAssets lists the names of the assets drawing a card reads: its
+// template, the fonts and every icon the card shows. The art is not an
+// asset. Cards that don't fit the game are drawn from no assets.
+func (r *Renderer) CardAssets(card interface{}) []string {
+	if r.game.CheckCard(card) != nil {
+		return nil
+	}
+	var names []string
+	switch card := card.(type) {
+	case LegislationCard:
+		names = append(names, r.layout.Templates.Legislation, r.layout.Regions[RegionTitle].Font)
+		for i, opinion := range card.Opinions {
+			if opinion != Indifferent {
+				names = append(names, r.game.stampAsset(i, opinion))
+			}
+		}
+		for i, effect := range card.Effects {
+			if effect != 0 {
+				names = append(names, r.game.effectAsset(i, effect))
+			}
+		}
+		if card.Cost.Value != 0 {
+			names = append(names, r.game.costAsset(card.Cost))
+		}
+	case ActionCard:
+		names = append(names, r.layout.Templates.Action)
+		for _, region := range []string{RegionTitle, RegionDescription, RegionRedText} {
+			if font := r.layout.Regions[region].Font; !contains(names, font) {
+				names = append(names, font)
+			}
+		}
+		if card.Symbol != NoSymbol {
+			names = append(names, r.game.symbolAsset(card.Symbol))
+		}
+		if card.RedText != "" {
+			names = append(names, ribbonAsset)
+		}
+		if card.Cost.Value != 0 {
+			names = append(names, r.game.costAsset(card.Cost))
+		}
+	}
+	return names
+}
+
+func contains(list []string, value string) bool {
+	return indexOf(list, value) >= 0
+}
@@ -0,0 +1,83 @@
+package sejm
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// testArt is a plain picture standing in for the art of every card.
+var testArt = func() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+	var b bytes.Buffer
+	png.Encode(&b, img)
+	return b.Bytes()
+}()
+
+func openTestArt(path string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(testArt)), nil
+}
+
+func newTestRenderer() *Renderer {
+	return NewRenderer(Config{OpenArt: openTestArt})
+}
+
+// testDeck is a legislation and an action card of every kind the renderer
+// draws differently.
+var testDeck = []interface{}{
+	NewLegislationCard("art/a.png", "Ustawa o wszystkim", []Opinion{2, 1, 0, -1, -2, 0, 1, 0, 0, -1}, []int{1, -2, 0, 0, 3, 0, -1}, 2),
+	NewLegislationCard("art/b.png", "Ustawa o niczym", []Opinion{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, []int{0, 0, 0, 0, 0, 0, 0}, 0),
+	NewActionCard("art/c.png", "Przekupstwo", "Zagłosuj jeszcze raz nad odrzuconą ustawą.", "paperclip", 3, "cash", "Tylko raz na turę"),
+	NewActionCard("art/d.png", "Nic", "", NoSymbol, 0, "", ""),
+}
+
+func TestRender(t *testing.T) {
+	renderer := newTestRenderer()
+	width, height := DefaultLayout.Width, DefaultLayout.Height
+	for _, card := range testDeck {
+		img, err := renderer.Render(context.Background(), card)
+		if err != nil {
+			t.Fatalf("Render(%v): %v", card, err)
+		}
+		if size := img.Bounds().Size(); size.X != width || size.Y != height {
+			t.Errorf("Render(%v) is %v, want %dx%d", card, size, width, height)
+		}
+	}
+}
+
+// BenchmarkRenderCold draws every card with a new Renderer, decoding and
+// resizing its assets again, as the cards were drawn before they shared
+// an asset cache.
+func BenchmarkRenderCold(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		card := testDeck[i%len(testDeck)]
+		if _, err := newTestRenderer().Render(context.Background(), card); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRenderCached draws every card with one Renderer, as a deck is
+// drawn.
+func BenchmarkRenderCached(b *testing.B) {
+	renderer := newTestRenderer()
+	for _, card := range testDeck {
+		renderer.Render(context.Background(), card)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		card := testDeck[i%len(testDeck)]
+		if _, err := renderer.Render(context.Background(), card); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package sejm
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"strings"
+)
+
+// PaperSize is the size of a portrait sheet in millimetres.
+type PaperSize struct {
+	Width, Height float64
+}
+
+// PaperSizes are the sheets cards can be printed on, by lower case name.
+var PaperSizes = map[string]PaperSize{
+	"a4":     {210, 297},
+	"a3":     {297, 420},
+	"letter": {215.9, 279.4},
+}
+
+// Edges sheets are turned over on when they are printed on both sides
+const (
+	FlipLongEdge  = "long"
+	FlipShortEdge = "short"
+)
+
+// SheetConfig sets up the imposition of cards on print sheets. Lengths are
+// in millimetres.
+type SheetConfig struct {
+	Paper     PaperSize
+	Landscape bool
+
+	// Grid of cards on a sheet. 0 fits as many as the sheet holds.
+	Columns, Rows int
+
+	// Trim size of the cards, see Layout.TrimSize
+	CardWidth, CardHeight float64
+
+	Margin float64 // least space between the edge of the paper and the bleed of the cards
+	Gutter float64 // space between the bleeds of neighbouring cards
+	Bleed  float64 // how far card images extend past the trim, repeating their edge pixels
+
+	CropMarks bool // marks in the margin at every trim line
+
+	// Duplex follows every sheet of fronts with a sheet of their backs,
+	// mirrored so that each back lands behind its front when the sheet is
+	// turned over on the Flip edge.
+	Duplex bool
+	Flip   string
+}
+
+// Crop marks, in millimetres
+const (
+	cropMarkOffset = 2 // from the bleed of the cards
+	cropMarkLength = 5
+)
+
+const pointsPerMM = 72 / 25.4
+
+// SheetWriter lays cards out on sheets and writes them as a PDF. Each card
+// image is written once however many copies of it are printed.
+type SheetWriter struct {
+	config        SheetConfig
+	pdf           *pdfWriter
+	pageTree      int
+	pages         []int
+	width, height float64 // of the paper, turned
+	columns, rows int
+	left, top     float64 // corner of the grid
+	slots         []sheetSlot
+	backs         map[image.Image]int
+}
+
+// sheetSlot holds the object numbers of the images of a card on the sheet
+// being filled. back is 0 for cards without a back.
+type sheetSlot struct {
+	front, back int
+}
+
+// NewSheetWriter starts a PDF on w. It fails when not even one card fits
+// on the sheet, or the given grid doesn't.
+func NewSheetWriter(w io.Writer, config SheetConfig) (*SheetWriter, error) {
+	if config.CardWidth <= 0 || config.CardHeight <= 0 {
+		return nil, fmt.Errorf("card size: must be positive, got %vx%v mm", config.CardWidth, config.CardHeight)
+	}
+	if config.Paper.Width <= 0 || config.Paper.Height <= 0 {
+		return nil, fmt.Errorf("paper size: must be positive, got %vx%v mm", config.Paper.Width, config.Paper.Height)
+	}
+	if config.Margin < 0 || config.Gutter < 0 || config.Bleed < 0 {
+		return nil, fmt.Errorf("margin, gutter and bleed can't be negative")
+	}
+	switch config.Flip {
+	case "":
+		config.Flip = FlipLongEdge
+	case FlipLongEdge, FlipShortEdge:
+	default:
+		return nil, fmt.Errorf("flip: %q is not %s or %s", config.Flip, FlipLongEdge, FlipShortEdge)
+	}
+
+	s := &SheetWriter{config: config, backs: map[image.Image]int{}}
+	s.width, s.height = config.Paper.Width, config.Paper.Height
+	if config.Landscape {
+		s.width, s.height = s.height, s.width
+	}
+
+	cellWidth, cellHeight := s.cellSize()
+	var err error
+	if s.columns, err = fitCells(config.Columns, s.width-2*config.Margin, cellWidth, config.Gutter, "columns"); err != nil {
+		return nil, err
+	}
+	if s.rows, err = fitCells(config.Rows, s.height-2*config.Margin, cellHeight, config.Gutter, "rows"); err != nil {
+		return nil, err
+	}
+	s.left = (s.width - float64(s.columns)*(cellWidth+config.Gutter) + config.Gutter) / 2
+	s.top = (s.height - float64(s.rows)*(cellHeight+config.Gutter) + config.Gutter) / 2
+
+	s.pdf = newPDFWriter(w)
+	s.pageTree = s.pdf.reserve()
+	return s, nil
+}
+
+// fitCells returns how many cells of the given size fit in space, or
+// checks that the wanted number does.
+func fitCells(wanted int, space, cell, gutter float64, what string) (int, error) {
+	fits := int(math.Floor((space + gutter + 0.001) / (cell + gutter)))
+	switch {
+	case wanted < 0:
+		return 0, fmt.Errorf("%s: can't be negative", what)
+	case wanted > fits:
+		return 0, fmt.Errorf("%s: %d don't fit on the sheet, at most %d do", what, wanted, fits)
+	case fits == 0:
+		return 0, fmt.Errorf("%s: not even one card fits on the sheet", what)
+	case wanted > 0:
+		return wanted, nil
+	}
+	return fits, nil
+}
+
+// cellSize returns the size of a card with its bleed.
+func (s *SheetWriter) cellSize() (float64, float64) {
+	return s.config.CardWidth + 2*s.config.Bleed, s.config.CardHeight + 2*s.config.Bleed
+}
+
+// Grid returns the number of columns and rows of cards on a sheet.
+func (s *SheetWriter) Grid() (columns, rows int) {
+	return s.columns, s.rows
+}
+
+// Pages returns the number of pages written so far, backs included.
+func (s *SheetWriter) Pages() int {
+	return len(s.pages)
+}
+
+// Add places copies of a card. front is written at once, so it can be
+// dropped after Add returns; backs are written once for every distinct
+// image. back is only drawn when the config is duplex, and may be nil.
+func (s *SheetWriter) Add(front, back image.Image, copies int) error {
+	if copies < 1 {
+		return nil
+	}
+	frontImage, err := s.pdf.image(s.bleed(front))
+	if err != nil {
+		return err
+	}
+	backImage := 0
+	if s.config.Duplex && back != nil {
+		var ok bool
+		if backImage, ok = s.backs[back]; !ok {
+			if backImage, err = s.pdf.image(s.bleed(back)); err != nil {
+				return err
+			}
+			s.backs[back] = backImage
+		}
+	}
+
+	for i := 0; i < copies; i++ {
+		s.slots = append(s.slots, sheetSlot{frontImage, backImage})
+		if len(s.slots) == s.columns*s.rows {
+			s.flush()
+		}
+	}
+	return s.pdf.err
+}
+
+// bleed draws img onto a white canvas extended by the bleed on every
+// side, repeating the edge pixels of img outwards. Transparent parts of
+// img come out white, as they would on paper.
+func (s *SheetWriter) bleed(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	px := int(math.Round(s.config.Bleed * float64(bounds.Dx()) / s.config.CardWidth))
+	width, height := bounds.Dx(), bounds.Dy()
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width+2*px, height+2*px))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(px, px, px+width, px+height), img, bounds.Min, draw.Over)
+	if px == 0 {
+		return canvas
+	}
+
+	for y := px; y < px+height; y++ {
+		row := canvas.Pix[y*canvas.Stride : (y+1)*canvas.Stride]
+		left, right := row[px*4:px*4+4], row[(px+width-1)*4:(px+width)*4]
+		for x := 0; x < px; x++ {
+			copy(row[x*4:], left)
+			copy(row[(px+width+x)*4:], right)
+		}
+	}
+	first, last := canvas.Pix[px*canvas.Stride:(px+1)*canvas.Stride], canvas.Pix[(px+height-1)*canvas.Stride:(px+height)*canvas.Stride]
+	for y := 0; y < px; y++ {
+		copy(canvas.Pix[y*canvas.Stride:], first)
+		copy(canvas.Pix[(px+height+y)*canvas.Stride:], last)
+	}
+	return canvas
+}
+
+// flush writes the sheet being filled, and its backs.
+func (s *SheetWriter) flush() {
+	if len(s.slots) == 0 {
+		return
+	}
+	fronts := make([]int, len(s.slots))
+	backs := make([]int, len(s.slots))
+	mirrorColumns := (s.config.Flip == FlipLongEdge) != s.config.Landscape
+	for i, slot := range s.slots {
+		fronts[i] = slot.front
+		column, row := i%s.columns, i/s.columns
+		if mirrorColumns {
+			column = s.columns - 1 - column
+		} else {
+			row = s.rows - 1 - row
+		}
+		// Backs are always laid out on a full grid
+		for len(backs) <= row*s.columns+column {
+			backs = append(backs, 0)
+		}
+		backs[row*s.columns+column] = slot.back
+	}
+
+	s.page(fronts, s.config.CropMarks)
+	if s.config.Duplex {
+		s.page(backs, false)
+	}
+	s.slots = s.slots[:0]
+}
+
+// page writes a page with the given images, row by row; 0 leaves a cell
+// empty.
+func (s *SheetWriter) page(images []int, cropMarks bool) {
+	cellWidth, cellHeight := s.cellSize()
+	var ops strings.Builder
+	var resources strings.Builder
+	named := map[int]bool{}
+	for i, img := range images {
+		if img == 0 {
+			continue
+		}
+		column, row := i%s.columns, i/s.columns
+		x := s.left + float64(column)*(cellWidth+s.config.Gutter)
+		y := s.top + float64(row)*(cellHeight+s.config.Gutter)
+		// PDF pages start at the bottom left
+		fmt.Fprintf(&ops, "q %.3f 0 0 %.3f %.3f %.3f cm /I%d Do Q\n",
+			cellWidth*pointsPerMM, cellHeight*pointsPerMM, x*pointsPerMM, (s.height-y-cellHeight)*pointsPerMM, img)
+		if !named[img] {
+			named[img] = true
+			fmt.Fprintf(&resources, " /I%d %d 0 R", img, img)
+		}
+	}
+	if cropMarks {
+		s.cropMarks(&ops)
+	}
+
+	content := s.pdf.content([]byte(ops.String()))
+	s.pages = append(s.pages, s.pdf.object(fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.3f %.3f] /Resources << /XObject <<%s >> >> /Contents %d 0 R >>",
+		s.pageTree, s.width*pointsPerMM, s.height*pointsPerMM, resources.String(), content)))
+}
+
+// cropMarks draws a mark in the margins at both ends of every trim line,
+// shortened to fit narrow margins.
+func (s *SheetWriter) cropMarks(ops *strings.Builder) {
+	cellWidth, cellHeight := s.cellSize()
+	right := s.left + float64(s.columns)*(cellWidth+s.config.Gutter) - s.config.Gutter
+	bottom := s.top + float64(s.rows)*(cellHeight+s.config.Gutter) - s.config.Gutter
+	length := math.Min(cropMarkLength, math.Min(s.left, s.top)-cropMarkOffset)
+	if length <= 0 {
+		return
+	}
+
+	line := func(x1, y1, x2, y2 float64) {
+		fmt.Fprintf(ops, "%.3f %.3f m %.3f %.3f l S\n",
+			x1*pointsPerMM, (s.height-y1)*pointsPerMM, x2*pointsPerMM, (s.height-y2)*pointsPerMM)
+	}
+	ops.WriteString("q 0.25 w 0 G\n")
+	for column := 0; column < s.columns; column++ {
+		trim := s.left + float64(column)*(cellWidth+s.config.Gutter) + s.config.Bleed
+		for _, x := range []float64{trim, trim + s.config.CardWidth} {
+			line(x, s.top-cropMarkOffset, x, s.top-cropMarkOffset-length)
+			line(x, bottom+cropMarkOffset, x, bottom+cropMarkOffset+length)
+		}
+	}
+	for row := 0; row < s.rows; row++ {
+		trim := s.top + float64(row)*(cellHeight+s.config.Gutter) + s.config.Bleed
+		for _, y := range []float64{trim, trim + s.config.CardHeight} {
+			line(s.left-cropMarkOffset, y, s.left-cropMarkOffset-length, y)
+			line(right+cropMarkOffset, y, right+cropMarkOffset+length, y)
+		}
+	}
+	ops.WriteString("Q\n")
+}
+
+// Close writes the last, partly filled sheet and finishes the PDF. It
+// fails when no card was added.
+func (s *SheetWriter) Close() error {
+	s.flush()
+	if len(s.pages) == 0 {
+		return fmt.Errorf("no cards to print")
+	}
+	kids := make([]string, len(s.pages))
+	for i, page := range s.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	s.pdf.objectAt(s.pageTree, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(s.pages)))
+	catalog := s.pdf.object(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", s.pageTree))
+	return s.pdf.close(catalog)
+}
@@ -0,0 +1,344 @@
+package sejm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sejmSeats is the size of the Sejm, split evenly between the groups of
+// the default vote model.
+const sejmSeats = 460
+
+// VoteModel is how the Sejm votes on legislation. Every deputy that turns
+// up votes yes with the chance their group's opinion of the card gives,
+// moved by the influence of the players, and the players buy more votes
+// with the trust and cash they spend. A card passes with more yes votes
+// than no votes once a quorum is present.
+type VoteModel struct {
+	// Seats held by each group, by id, name or alias
+	Seats map[string]int `yaml:"seats" json:"seats"`
+
+	// Chance a deputy votes yes, by their group's opinion
+	Yes struct {
+		ExtraFor     float64 `yaml:"extra_for" json:"extra_for"`
+		For          float64 `yaml:"for" json:"for"`
+		Indifferent  float64 `yaml:"indifferent" json:"indifferent"`
+		Against      float64 `yaml:"against" json:"against"`
+		ExtraAgainst float64 `yaml:"extra_against" json:"extra_against"`
+	} `yaml:"yes" json:"yes"`
+
+	// Chance a deputy turns up, and the share of the seats that must be
+	// present for a vote to count
+	Turnout float64 `yaml:"turnout" json:"turnout"`
+	Quorum  float64 `yaml:"quorum" json:"quorum"`
+
+	// Added to the chance of every deputy voting yes, negative for players
+	// working against legislation
+	Influence float64 `yaml:"influence" json:"influence"`
+
+	// Trust and cash spent on a vote, each from 0 to these at random, and
+	// the no votes a point of each turns into yes votes
+	Trust      int `yaml:"trust" json:"trust"`
+	Cash       int `yaml:"cash" json:"cash"`
+	TrustVotes int `yaml:"trust_votes" json:"trust_votes"`
+	CashVotes  int `yaml:"cash_votes" json:"cash_votes"`
+
+	game  *Game
+	seats []int // by group
+	total int
+	yes   [5]float64
+}
+
+// DefaultVoteModel returns a Sejm of 460 seats split evenly between the
+// groups of the game, where deputies follow their group's opinion most of
+// the time and players spend nothing.
+func (g *Game) DefaultVoteModel() *VoteModel {
+	model := &VoteModel{Seats: map[string]int{}, Turnout: 0.9, Quorum: 0.5, TrustVotes: 3, CashVotes: 5}
+	model.Yes.ExtraFor, model.Yes.For, model.Yes.Indifferent, model.Yes.Against, model.Yes.ExtraAgainst = 0.95, 0.8, 0.5, 0.2, 0.05
+	for i, group := range g.Groups {
+		model.Seats[group.ID] = sejmSeats / len(g.Groups)
+		if i < sejmSeats%len(g.Groups) {
+			model.Seats[group.ID]++
+		}
+	}
+	if err := model.check(g); err != nil {
+		panic("default vote model: " + err.Error())
+	}
+	return model
+}
+
+// LoadVoteModel reads a JSON or YAML vote model file over the default
+// model. Seats replace the default seats as a whole.
+func (g *Game) LoadVoteModel(path string) (*VoteModel, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	model, err := g.ReadVoteModel(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return model, nil
+}
+
+// ReadVoteModel reads a JSON or YAML vote model over the default model and
+// checks it against the game.
+func (g *Game) ReadVoteModel(r io.Reader) (*VoteModel, error) {
+	model := g.DefaultVoteModel()
+	var seats struct {
+		Seats map[string]int `yaml:"seats"`
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading vote model: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &seats); err != nil {
+		return nil, fmt.Errorf("reading vote model: %v", err)
+	}
+	if seats.Seats != nil {
+		model.Seats = nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(model); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading vote model: %v", err)
+	}
+	if err := model.check(g); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (m *VoteModel) check(g *Game) error {
+	m.game = g
+	m.seats = make([]int, len(g.Groups))
+	total := 0
+	for name, seats := range m.Seats {
+		i := g.GroupIndex(name)
+		if i < 0 {
+			return fmt.Errorf("seats: %q is not a group, expected one of %s", name, listOr(g.GroupIDs()))
+		}
+		if seats < 0 {
+			return fmt.Errorf("seats.%s: %d is negative", name, seats)
+		}
+		m.seats[i] += seats
+		total += seats
+	}
+	if total == 0 {
+		return fmt.Errorf("seats: at least one group must hold seats")
+	}
+	m.total = total
+
+	m.yes = [5]float64{m.Yes.ExtraAgainst, m.Yes.Against, m.Yes.Indifferent, m.Yes.For, m.Yes.ExtraFor}
+	for name, chance := range map[string]float64{
+		"yes.extra_for": m.Yes.ExtraFor, "yes.for": m.Yes.For, "yes.indifferent": m.Yes.Indifferent,
+		"yes.against": m.Yes.Against, "yes.extra_against": m.Yes.ExtraAgainst,
+		"turnout": m.Turnout, "quorum": m.Quorum,
+	} {
+		if chance < 0 || chance > 1 {
+			return fmt.Errorf("%s: %v is not from 0 to 1", name, chance)
+		}
+	}
+	if m.Influence < -1 || m.Influence > 1 {
+		return fmt.Errorf("influence: %v is not from -1 to 1", m.Influence)
+	}
+	if m.Trust < 0 || m.Cash < 0 || m.TrustVotes < 0 || m.CashVotes < 0 {
+		return fmt.Errorf("trust, cash, trust_votes and cash_votes: can't be negative")
+	}
+	return nil
+}
+
+// TotalSeats returns the seats of every group together.
+func (m *VoteModel) TotalSeats() int {
+	return m.total
+}
+
+// Bill is a legislation card put to the vote Copies times in every game.
+type Bill struct {
+	Card   LegislationCard
+	Copies int
+}
+
+// Simulation is the outcome of many games. Passed and Yes are by bill,
+// Drift by indicator: the sum of the effects of the bills that passed in
+// a game.
+type Simulation struct {
+	Games int
+	Seed  int64
+
+	Passed []int   // votes the bill passed, every copy voting in every game
+	Votes  []int   // times it was put to the vote
+	Yes    []int64 // yes votes over every vote
+
+	drift, driftSquares []int64
+}
+
+// PassRate returns the chance the bill passes a vote.
+func (s *Simulation) PassRate(bill int) float64 {
+	if s.Votes[bill] == 0 {
+		return 0
+	}
+	return float64(s.Passed[bill]) / float64(s.Votes[bill])
+}
+
+// MeanYes returns the average yes votes the bill gets.
+func (s *Simulation) MeanYes(bill int) float64 {
+	if s.Votes[bill] == 0 {
+		return 0
+	}
+	return float64(s.Yes[bill]) / float64(s.Votes[bill])
+}
+
+// Drift returns the mean change of an indicator over a game and its
+// standard deviation between games.
+func (s *Simulation) Drift(indicator int) (mean, deviation float64) {
+	if s.Games == 0 {
+		return 0, 0
+	}
+	n := float64(s.Games)
+	mean = float64(s.drift[indicator]) / n
+	variance := float64(s.driftSquares[indicator])/n - mean*mean
+	return mean, math.Sqrt(math.Max(variance, 0))
+}
+
+// Simulate plays games in which every bill is put to the vote, on up to
+// workers games at a time. Every game draws from a source seeded by mixing
+// seed and its number, so the outcome depends on the seed alone and
+// neighbouring seeds share no games. Bills that
+// don't fit the game never pass.
+func (m *VoteModel) Simulate(bills []Bill, games int, seed int64, workers int) *Simulation {
+	indicators := len(m.game.Indicators)
+	if workers < 1 {
+		workers = 1
+	}
+
+	var mu sync.Mutex
+	total := newSimulation(len(bills), indicators)
+	total.Games, total.Seed = games, seed
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			part := newSimulation(len(bills), indicators)
+			drift := make([]int64, indicators)
+			for game := range jobs {
+				rng := rand.New(rand.NewSource(gameSeed(seed, game)))
+				for i := range drift {
+					drift[i] = 0
+				}
+				for b, bill := range bills {
+					if m.game.CheckCard(bill.Card) != nil {
+						continue
+					}
+					for c := 0; c < bill.Copies; c++ {
+						passed, yes := m.vote(rng, bill.Card)
+						part.Votes[b]++
+						part.Yes[b] += int64(yes)
+						if !passed {
+							continue
+						}
+						part.Passed[b]++
+						for i, effect := range bill.Card.Effects {
+							drift[i] += int64(effect)
+						}
+					}
+				}
+				for i, value := range drift {
+					part.drift[i] += value
+					part.driftSquares[i] += value * value
+				}
+			}
+
+			mu.Lock()
+			total.add(part)
+			mu.Unlock()
+		}()
+	}
+	for game := 0; game < games; game++ {
+		jobs <- game
+	}
+	close(jobs)
+	wg.Wait()
+	return total
+}
+
+// gameSeed mixes the seed of a simulation with the number of a game with
+// splitmix64, so that seeds s and s+1 don't play the same games shifted by
+// one.
+func gameSeed(seed int64, game int) int64 {
+	z := uint64(seed) ^ uint64(game)*0x9e3779b97f4a7c15
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return int64(z ^ z>>31)
+}
+
+func newSimulation(bills, indicators int) *Simulation {
+	return &Simulation{
+		Passed:       make([]int, bills),
+		Votes:        make([]int, bills),
+		Yes:          make([]int64, bills),
+		drift:        make([]int64, indicators),
+		driftSquares: make([]int64, indicators),
+	}
+}
+
+// add sums the counts of part into s. Counts are integers so that the sum
+// doesn't depend on how games were split between workers.
+func (s *Simulation) add(part *Simulation) {
+	for i := range s.Passed {
+		s.Passed[i] += part.Passed[i]
+		s.Votes[i] += part.Votes[i]
+		s.Yes[i] += part.Yes[i]
+	}
+	for i := range s.drift {
+		s.drift[i] += part.drift[i]
+		s.driftSquares[i] += part.driftSquares[i]
+	}
+}
+
+// vote puts a card to the vote of the Sejm once.
+func (m *VoteModel) vote(rng *rand.Rand, card LegislationCard) (passed bool, yes int) {
+	no, present := 0, 0
+	for group, seats := range m.seats {
+		opinion := min(max(card.Opinions[group], ExtraAgainst), ExtraFor)
+		chance := math.Min(math.Max(m.yes[opinion-ExtraAgainst]+m.Influence, 0), 1)
+		for d := 0; d < seats; d++ {
+			switch u := rng.Float64(); {
+			case u < m.Turnout*chance:
+				yes++
+			case u < m.Turnout:
+				no++
+			default:
+				continue
+			}
+			present++
+		}
+	}
+
+	bought := 0
+	if m.Trust > 0 {
+		bought += rng.Intn(m.Trust+1) * m.TrustVotes
+	}
+	if m.Cash > 0 {
+		bought += rng.Intn(m.Cash+1) * m.CashVotes
+	}
+	bought = min(bought, no)
+	yes, no = yes+bought, no-bought
+
+	quorum := float64(present) >= m.Quorum*float64(m.total)
+	return quorum && yes > no, yes
+}
@@ -0,0 +1,78 @@
+package sejm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testBills = []Bill{
+	{NewLegislationCard("a", "Popierana", []Opinion{2, 2, 1, 1, 1, 0, 0, 0, 0, 0}, []int{1, 0, 0, 0, 0, 0, 0}, 1), 2},
+	{NewLegislationCard("b", "Sporna", []Opinion{2, -2, 1, -1, 0, 0, 1, -1, 0, 0}, []int{0, -1, 2, 0, 0, 0, 0}, 1), 1},
+	{NewLegislationCard("c", "Odrzucana", []Opinion{-2, -2, -1, -1, -1, 0, 0, 0, 0, 0}, []int{0, 0, 0, 0, 0, 0, -3}, 1), 1},
+}
+
+func TestSimulateWorkers(t *testing.T) {
+	model := DefaultGame.DefaultVoteModel()
+	want := model.Simulate(testBills, 300, 7, 1)
+	for _, workers := range []int{2, 3, 8} {
+		if got := model.Simulate(testBills, 300, 7, workers); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers: %+v, want %+v as with 1 worker", workers, got, want)
+		}
+	}
+
+	if rate := want.PassRate(0); rate < 0.9 {
+		t.Errorf("pass rate of a supported bill %v, want at least 0.9", rate)
+	}
+	if rate := want.PassRate(2); rate > 0.1 {
+		t.Errorf("pass rate of an opposed bill %v, want at most 0.1", rate)
+	}
+	if want.Votes[0] != 600 {
+		t.Errorf("bill of 2 copies voted %d times over 300 games, want 600", want.Votes[0])
+	}
+}
+
+func TestSimulateSeeds(t *testing.T) {
+	model := DefaultGame.DefaultVoteModel()
+	first := model.Simulate(testBills, 300, 1, 4)
+	second := model.Simulate(testBills, 300, 2, 4)
+	if reflect.DeepEqual(first.Yes, second.Yes) {
+		t.Errorf("seeds 1 and 2 gave the same yes votes %v", first.Yes)
+	}
+
+	// Seeds next to each other used to play the same games shifted by one
+	for game := 0; game < 100; game++ {
+		if gameSeed(1, game+1) == gameSeed(2, game) {
+			t.Fatalf("game %d of seed 2 plays game %d of seed 1", game, game+1)
+		}
+	}
+}
+
+func TestReadVoteModel(t *testing.T) {
+	tests := []struct {
+		yaml  string
+		seats int
+		err   string
+	}{
+		{"", 460, ""},
+		{"turnout: 1", 460, ""},
+		{"seats: {kat: 100, Socjaliści: 50}", 150, ""},
+		{"seats: {kat: 100}\nquorum: 2", 0, "quorum: 2 is not from 0 to 1"},
+		{"seats: {nobody: 1}", 0, `seats: "nobody" is not a group`},
+		{"seats: {kat: 0}", 0, "at least one group must hold seats"},
+		{"turnot: 1", 0, "field turnot not found"},
+	}
+	for _, test := range tests {
+		model, err := DefaultGame.ReadVoteModel(strings.NewReader(test.yaml))
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ReadVoteModel(%q): error %v, want %q", test.yaml, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("ReadVoteModel(%q): %v", test.yaml, err)
+		case model.TotalSeats() != test.seats:
+			t.Errorf("ReadVoteModel(%q): %d seats, want %d", test.yaml, model.TotalSeats(), test.seats)
+		}
+	}
+}
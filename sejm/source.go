@@ -0,0 +1,90 @@
+package sejm
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// AssetLayer is one place assets are looked up in.
+type AssetLayer struct {
+	Name string // where the assets come from, like "built-in" or a directory
+	FS   fs.FS
+}
+
+// AssetSource is an fs.FS serving every asset from the first of its layers
+// that has it, so that custom assets replace the built-in ones file by
+// file. Directories are opened from the first layer that has them and are
+// not merged.
+type AssetSource struct {
+	layers []AssetLayer
+}
+
+func NewAssetSource(layers ...AssetLayer) *AssetSource {
+	return &AssetSource{layers: layers}
+}
+
+func (s *AssetSource) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, layer := range s.layers {
+		file, err := layer.FS.Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Source returns the name of the layer an asset is served from.
+func (s *AssetSource) Source(name string) (string, error) {
+	for _, layer := range s.layers {
+		_, err := fs.Stat(layer.FS, name)
+		if err == nil {
+			return layer.Name, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// AssetInfo tells where an asset is served from.
+type AssetInfo struct {
+	Name     string
+	Source   string   // the layer it is served from
+	Replaces []string // the later layers that have it too
+}
+
+// Assets lists the files of every layer, sorted by name.
+func (s *AssetSource) Assets() ([]AssetInfo, error) {
+	byName := map[string]*AssetInfo{}
+	for _, layer := range s.layers {
+		err := fs.WalkDir(layer.FS, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			if info, ok := byName[name]; ok {
+				info.Replaces = append(info.Replaces, layer.Name)
+			} else {
+				byName[name] = &AssetInfo{Name: name, Source: layer.Name}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	infos := make([]AssetInfo, 0, len(byName))
+	for _, info := range byName {
+		infos = append(infos, *info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
@@ -0,0 +1,103 @@
+package sejm
+
+import "sort"
+
+// Stats sums up a deck for balancing it: how the groups vote on its
+// legislation, what the legislation does to the indicators and what its
+// action cards cost. Cards are counted once per copy.
+type Stats struct {
+	game *Game
+
+	Legislation int // legislation cards
+	Action      int // action cards
+
+	// Opinions counts the legislation cards by group and opinion, from
+	// ExtraAgainst to ExtraFor.
+	Opinions [][5]int
+
+	// Effects sums the effects of the legislation cards by indicator,
+	// Changes counts the cards changing it.
+	Effects []int
+	Changes []int
+
+	// Costs counts the action cards by currency and cost. Action cards
+	// costing nothing are counted under NoCost.
+	Costs  map[Currency]map[int]int
+	NoCost int
+}
+
+// NewStats returns empty stats of the cards of the game.
+func (g *Game) NewStats() *Stats {
+	return &Stats{
+		game:     g,
+		Opinions: make([][5]int, len(g.Groups)),
+		Effects:  make([]int, len(g.Indicators)),
+		Changes:  make([]int, len(g.Indicators)),
+		Costs:    map[Currency]map[int]int{},
+	}
+}
+
+// Add counts copies of a LegislationCard or an ActionCard. Cards that
+// don't fit the game are left out, as are opinions beyond ExtraFor or
+// ExtraAgainst.
+func (s *Stats) Add(card interface{}, copies int) {
+	if s.game.CheckCard(card) != nil {
+		return
+	}
+	switch card := card.(type) {
+	case LegislationCard:
+		s.Legislation += copies
+		for i, opinion := range card.Opinions {
+			if opinion >= ExtraAgainst && opinion <= ExtraFor {
+				s.Opinions[i][opinion-ExtraAgainst] += copies
+			}
+		}
+		for i, effect := range card.Effects {
+			s.Effects[i] += effect * copies
+			if effect != 0 {
+				s.Changes[i] += copies
+			}
+		}
+	case ActionCard:
+		s.Action += copies
+		if card.Cost.Value == 0 {
+			s.NoCost += copies
+			return
+		}
+		currency, _ := s.game.Currency(string(card.Cost.Currency))
+		if s.Costs[currency] == nil {
+			s.Costs[currency] = map[int]int{}
+		}
+		s.Costs[currency][card.Cost.Value] += copies
+	}
+}
+
+// Opinion returns how many legislation cards the group has the opinion of.
+func (s *Stats) Opinion(group int, opinion Opinion) int {
+	return s.Opinions[group][opinion-ExtraAgainst]
+}
+
+// AverageEffect returns the effect of an average legislation card on an
+// indicator.
+func (s *Stats) AverageEffect(indicator int) float64 {
+	if s.Legislation == 0 {
+		return 0
+	}
+	return float64(s.Effects[indicator]) / float64(s.Legislation)
+}
+
+// CostValues returns every cost of the action cards, from the lowest.
+func (s *Stats) CostValues() []int {
+	seen := map[int]bool{}
+	var values []int
+	for _, costs := range s.Costs {
+		for value := range costs {
+			if !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+	}
+	sort.Ints(values)
+	return values
+}
@@ -0,0 +1,73 @@
+package sejm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatsAdd(t *testing.T) {
+	stats := DefaultGame.NewStats()
+	stats.Add(NewLegislationCard("a", "A", []Opinion{2, 1, -1, -2, 0, 0, 0, 0, 0, 0}, []int{1, 0, -2, 0, 0, 0, 0}, 1), 2)
+	stats.Add(NewLegislationCard("b", "B", []Opinion{2, -2, 0, 0, 0, 0, 0, 0, 0, 3}, []int{-1, 0, 0, 0, 0, 0, 3}, 1), 1)
+	stats.Add(NewActionCard("c", "C", "", NoSymbol, 2, "trust", ""), 3)
+	stats.Add(NewActionCard("d", "D", "", NoSymbol, -1, "Gotówka", ""), 1)
+	stats.Add(NewActionCard("e", "E", "", NoSymbol, 2, "cash", ""), 1)
+	stats.Add(NewActionCard("f", "F", "", NoSymbol, 0, "", ""), 2)
+
+	// Cards that don't fit the game are left out
+	stats.Add(NewLegislationCard("g", "G", []Opinion{2}, []int{1, 0, 0, 0, 0, 0, 0}, 1), 5)
+	stats.Add(NewActionCard("h", "H", "", NoSymbol, 1, "zloto", ""), 5)
+	stats.Add(NewActionCard("i", "I", "", "kapelusz", 0, "", ""), 5)
+
+	if stats.Legislation != 3 || stats.Action != 7 {
+		t.Errorf("%d legislation and %d action cards, want 3 and 7", stats.Legislation, stats.Action)
+	}
+	opinions := []struct {
+		group   int
+		opinion Opinion
+		want    int
+	}{
+		{0, ExtraFor, 3},
+		{0, For, 0},
+		{1, For, 2},
+		{1, ExtraAgainst, 1},
+		{2, Against, 2},
+		{3, ExtraAgainst, 2},
+		{4, Indifferent, 3},
+		// An opinion beyond ExtraFor is not counted, not even as indifferent
+		{9, Indifferent, 2},
+		{9, ExtraFor, 0},
+	}
+	for _, test := range opinions {
+		if got := stats.Opinion(test.group, test.opinion); got != test.want {
+			t.Errorf("group %d has opinion %d of %d cards, want %d", test.group, test.opinion, got, test.want)
+		}
+	}
+	if want := []int{1, 0, -4, 0, 0, 0, 3}; !reflect.DeepEqual(stats.Effects, want) {
+		t.Errorf("effects %v, want %v", stats.Effects, want)
+	}
+	if want := []int{3, 0, 2, 0, 0, 0, 1}; !reflect.DeepEqual(stats.Changes, want) {
+		t.Errorf("changes %v, want %v", stats.Changes, want)
+	}
+	if got := stats.AverageEffect(2); got != -4.0/3 {
+		t.Errorf("average effect %v, want %v", got, -4.0/3)
+	}
+
+	if stats.NoCost != 2 {
+		t.Errorf("%d free action cards, want 2", stats.NoCost)
+	}
+	wantCosts := map[Currency]map[int]int{"trust": {2: 3}, "cash": {-1: 1, 2: 1}}
+	if !reflect.DeepEqual(stats.Costs, wantCosts) {
+		t.Errorf("costs %v, want %v", stats.Costs, wantCosts)
+	}
+	if want := []int{-1, 2}; !reflect.DeepEqual(stats.CostValues(), want) {
+		t.Errorf("cost values %v, want %v", stats.CostValues(), want)
+	}
+}
+
+func TestStatsEmpty(t *testing.T) {
+	stats := DefaultGame.NewStats()
+	if stats.AverageEffect(0) != 0 || len(stats.CostValues()) != 0 {
+		t.Errorf("empty stats have average effect %v and costs %v", stats.AverageEffect(0), stats.CostValues())
+	}
+}
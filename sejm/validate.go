@@ -0,0 +1,400 @@
+package sejm
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity says how much breaking a rule matters. Rules that are off are
+// not checked.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityOff     Severity = "off"
+)
+
+// Names of the rules, as in rules files and Problem.Rule
+const (
+	RuleCard         = "card" // the card fits the game at all, always an error
+	RuleOpinionRange = "opinion_range"
+	RuleStampSlots   = "stamp_slots"
+	RuleEffectRange  = "effect_range"
+	RuleCostRange    = "cost_range"
+	RuleCurrency     = "currency"
+	RuleArt          = "art"
+	RuleTextFit      = "text_fit"
+	RuleExtraFields  = "extra_fields"
+)
+
+// Rules are the design rules Validate checks cards against. Rules files
+// set the fields they name and keep the defaults of the others.
+type Rules struct {
+	OpinionRange RangeRule    `yaml:"opinion_range" json:"opinion_range"`
+	StampSlots   StampRule    `yaml:"stamp_slots" json:"stamp_slots"`
+	EffectRange  EffectRule   `yaml:"effect_range" json:"effect_range"`
+	CostRange    RangeRule    `yaml:"cost_range" json:"cost_range"`
+	Currency     CurrencyRule `yaml:"currency" json:"currency"`
+	Art          Rule         `yaml:"art" json:"art"`
+	TextFit      Rule         `yaml:"text_fit" json:"text_fit"`
+	ExtraFields  Rule         `yaml:"extra_fields" json:"extra_fields"`
+}
+
+type Rule struct {
+	Severity Severity `yaml:"severity" json:"severity"`
+}
+
+// RangeRule keeps values from Min to Max. Costs of 0 are never checked.
+type RangeRule struct {
+	Rule `yaml:",inline"`
+	Min  int `yaml:"min" json:"min"`
+	Max  int `yaml:"max" json:"max"`
+}
+
+// StampRule limits the groups for and against a legislation card. The
+// limits can't be more than the layout has stamp cells for.
+type StampRule struct {
+	Rule       `yaml:",inline"`
+	MaxFor     int `yaml:"max_for" json:"max_for"`
+	MaxAgainst int `yaml:"max_against" json:"max_against"`
+}
+
+// EffectRule limits the points of an effect and the indicators a
+// legislation card changes.
+type EffectRule struct {
+	Rule       `yaml:",inline"`
+	MaxPoints  int `yaml:"max_points" json:"max_points"`
+	MaxEffects int `yaml:"max_effects" json:"max_effects"`
+}
+
+// CurrencyRule lists the currencies each type of card may cost.
+type CurrencyRule struct {
+	Rule        `yaml:",inline"`
+	Legislation []Currency `yaml:"legislation" json:"legislation"`
+	Action      []Currency `yaml:"action" json:"action"`
+}
+
+// Problem is a rule a card breaks. Field names the part of the card, like
+// opinions.kat or title.
+type Problem struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Field    string   `json:"field,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Field == "" {
+		return fmt.Sprintf("%s: %s [%s]", p.Severity, p.Message, p.Rule)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", p.Severity, p.Field, p.Message, p.Rule)
+}
+
+// maxEffectPoints is the most points of an effect the cards of the printed
+// game have, for layouts that don't limit the rows of the effects grid.
+const maxEffectPoints = 3
+
+// DefaultRules returns rules within which every card draws as intended
+// with the layout and game of the renderer: opinions from ExtraAgainst to
+// ExtraFor, no more stamps or effect icons than fit on the card, costs with
+// a badge, legislation costing the legislation currency, readable art and
+// text within its box.
+func (r *Renderer) DefaultRules() *Rules {
+	effects := r.layout.Regions[RegionEffects]
+	points, columns := effects.Rows, effects.Columns
+	if points == 0 {
+		points = maxEffectPoints
+	}
+	if columns == 0 && effects.StepX > 0 {
+		columns = (r.layout.Width-effects.X-effects.Width)/effects.StepX + 1
+	}
+
+	rules := &Rules{
+		OpinionRange: RangeRule{Rule{SeverityError}, int(ExtraAgainst), int(ExtraFor)},
+		StampSlots:   StampRule{Rule{SeverityError}, r.layout.Regions[RegionStampsFor].Cells(), r.layout.Regions[RegionStampsAgainst].Cells()},
+		EffectRange:  EffectRule{Rule{SeverityError}, points, columns},
+		CostRange:    RangeRule{Rule{SeverityError}, -5, 5},
+		Currency:     CurrencyRule{Rule: Rule{SeverityError}, Legislation: []Currency{r.game.LegislationCurrency}},
+		Art:          Rule{SeverityError},
+		TextFit:      Rule{SeverityWarning},
+		ExtraFields:  Rule{SeverityWarning},
+	}
+	for _, currency := range r.game.Currencies {
+		rules.Currency.Action = append(rules.Currency.Action, currency.ID)
+	}
+	return rules
+}
+
+// LoadRules reads a JSON or YAML rules file over the default rules.
+func (r *Renderer) LoadRules(path string) (*Rules, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules, err := r.ReadRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rules, nil
+}
+
+// ReadRules reads JSON or YAML rules over the default rules and checks
+// them against the layout and game of the renderer.
+func (r *Renderer) ReadRules(reader io.Reader) (*Rules, error) {
+	rules := r.DefaultRules()
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(rules); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading rules: %v", err)
+	}
+	if err := r.checkRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *Renderer) checkRules(rules *Rules) error {
+	severities := map[string]Severity{
+		RuleOpinionRange: rules.OpinionRange.Severity,
+		RuleStampSlots:   rules.StampSlots.Severity,
+		RuleEffectRange:  rules.EffectRange.Severity,
+		RuleCostRange:    rules.CostRange.Severity,
+		RuleCurrency:     rules.Currency.Severity,
+		RuleArt:          rules.Art.Severity,
+		RuleTextFit:      rules.TextFit.Severity,
+		RuleExtraFields:  rules.ExtraFields.Severity,
+	}
+	for name, severity := range severities {
+		switch severity {
+		case SeverityError, SeverityWarning, SeverityOff:
+		default:
+			return fmt.Errorf("%s.severity: %q is not error, warning or off", name, severity)
+		}
+	}
+
+	for name, rule := range map[string]RangeRule{RuleOpinionRange: rules.OpinionRange, RuleCostRange: rules.CostRange} {
+		if rule.Min > rule.Max {
+			return fmt.Errorf("%s: min %d is more than max %d", name, rule.Min, rule.Max)
+		}
+	}
+	if cells := r.layout.Regions[RegionStampsFor].Cells(); rules.StampSlots.MaxFor < 0 || rules.StampSlots.MaxFor > cells {
+		return fmt.Errorf("%s.max_for: must be from 0 to the %d stamps the layout has room for", RuleStampSlots, cells)
+	}
+	if cells := r.layout.Regions[RegionStampsAgainst].Cells(); rules.StampSlots.MaxAgainst < 0 || rules.StampSlots.MaxAgainst > cells {
+		return fmt.Errorf("%s.max_against: must be from 0 to the %d stamps the layout has room for", RuleStampSlots, cells)
+	}
+	if rules.EffectRange.MaxPoints < 0 || rules.EffectRange.MaxEffects < 0 {
+		return fmt.Errorf("%s: max_points and max_effects can't be negative", RuleEffectRange)
+	}
+	// Currencies may be named as in decks, cards are checked by their ids
+	for _, currencies := range [][]Currency{rules.Currency.Legislation, rules.Currency.Action} {
+		for i, currency := range currencies {
+			id, ok := r.game.Currency(string(currency))
+			if !ok {
+				return fmt.Errorf("%s: %q is not one of %s", RuleCurrency, currency, listOr(r.game.CurrencyIDs()))
+			}
+			currencies[i] = id
+		}
+	}
+	return nil
+}
+
+// Validate checks a LegislationCard or an ActionCard against rules, nil
+// for the default rules, without drawing it. Cards that don't fit the game
+// break the card rule and no other is checked.
+func (r *Renderer) Validate(card interface{}, rules *Rules) []Problem {
+	if rules == nil {
+		rules = r.DefaultRules()
+	}
+	if err := r.game.CheckCard(card); err != nil {
+		return []Problem{{Rule: RuleCard, Severity: SeverityError, Message: err.Error()}}
+	}
+
+	v := validation{r: r, rules: rules}
+	switch card := card.(type) {
+	case LegislationCard:
+		v.opinions(card.Opinions)
+		v.effects(card.Effects)
+		v.cost(card.Cost, rules.Currency.Legislation)
+		v.art(card.ArtPath)
+		v.title(card.Title)
+	case ActionCard:
+		v.cost(card.Cost, rules.Currency.Action)
+		v.art(card.ArtPath)
+		v.title(card.Title)
+		v.text(RegionDescription, card.Description)
+		if card.RedText != "" {
+			v.text(RegionRedText, card.RedText)
+		}
+	}
+	return v.problems
+}
+
+// ValidateCode checks the card code of a card against the rules that
+// apply to how it is written rather than to the card, which are fields
+// past the ones the card reads. rules is nil for the default rules.
+func (r *Renderer) ValidateCode(kind CardKind, code string, rules *Rules) []Problem {
+	if rules == nil {
+		rules = r.DefaultRules()
+	}
+	v := validation{r: r, rules: rules}
+	if err := ExtraFields(kind, code); err != nil {
+		v.report(RuleExtraFields, rules.ExtraFields.Severity, "code", "column %d: %q %s", err.Column, err.Value, err.Reason)
+	}
+	return v.problems
+}
+
+// validation collects the problems of a card.
+type validation struct {
+	r        *Renderer
+	rules    *Rules
+	problems []Problem
+}
+
+func (v *validation) report(rule string, severity Severity, field, format string, args ...interface{}) {
+	if severity == SeverityOff {
+		return
+	}
+	v.problems = append(v.problems, Problem{rule, severity, field, fmt.Sprintf(format, args...)})
+}
+
+func (v *validation) opinions(opinions []Opinion) {
+	rule := v.rules.OpinionRange
+	fors, againsts := 0, 0
+	for i, opinion := range opinions {
+		if int(opinion) < rule.Min || int(opinion) > rule.Max {
+			v.report(RuleOpinionRange, rule.Severity, "opinions."+v.r.game.Groups[i].ID, "%d is not from %d to %d", opinion, rule.Min, rule.Max)
+		}
+		switch {
+		case opinion > Indifferent:
+			fors++
+		case opinion < Indifferent:
+			againsts++
+		}
+	}
+
+	slots := v.rules.StampSlots
+	if fors > slots.MaxFor {
+		v.report(RuleStampSlots, slots.Severity, "opinions", "%d groups for, pick up to %d", fors, slots.MaxFor)
+	}
+	if againsts > slots.MaxAgainst {
+		v.report(RuleStampSlots, slots.Severity, "opinions", "%d groups against, pick up to %d", againsts, slots.MaxAgainst)
+	}
+}
+
+func (v *validation) effects(effects []int) {
+	rule := v.rules.EffectRange
+	count := 0
+	for i, effect := range effects {
+		if effect == 0 {
+			continue
+		}
+		count++
+		if rule.MaxPoints > 0 && (effect > rule.MaxPoints || effect < -rule.MaxPoints) {
+			v.report(RuleEffectRange, rule.Severity, "effects."+v.r.game.Indicators[i].ID, "%+d is more points than %d", effect, rule.MaxPoints)
+		}
+	}
+	if rule.MaxEffects > 0 && count > rule.MaxEffects {
+		v.report(RuleEffectRange, rule.Severity, "effects", "%d indicators change, pick up to %d", count, rule.MaxEffects)
+	}
+}
+
+func (v *validation) cost(cost Cost, currencies []Currency) {
+	if cost.Value == 0 {
+		return
+	}
+	rule := v.rules.CostRange
+	switch {
+	case cost.Value < rule.Min || cost.Value > rule.Max:
+		v.report(RuleCostRange, rule.Severity, "cost", "%d is not from %d to %d", cost.Value, rule.Min, rule.Max)
+	default:
+		badge := v.r.game.costAsset(cost)
+		if _, err := fs.Stat(v.r.assets.source, badge); err != nil {
+			v.report(RuleCostRange, rule.Severity, "cost", "%d has no badge: %v", cost.Value, err)
+		}
+	}
+
+	currency, _ := v.r.game.Currency(string(cost.Currency))
+	for _, allowed := range currencies {
+		if allowed == currency {
+			return
+		}
+	}
+	ids := make([]string, len(currencies))
+	for i, allowed := range currencies {
+		ids[i] = string(allowed)
+	}
+	v.report(RuleCurrency, v.rules.Currency.Severity, "cost", "%q is not one of %s", cost.Currency, listOr(ids))
+}
+
+// art decodes the whole art, not only its header, as drawing the card
+// would.
+func (v *validation) art(artPath string) {
+	rule := v.rules.Art
+	if rule.Severity == SeverityOff {
+		return
+	}
+	file, err := v.r.openArt(artPath)
+	if err != nil {
+		v.report(RuleArt, rule.Severity, "art", "%v", err)
+		return
+	}
+	defer file.Close()
+	if _, _, err := image.Decode(file); err != nil {
+		v.report(RuleArt, rule.Severity, "art", "decoding %s: %v", artPath, err)
+	}
+}
+
+func (v *validation) title(title string) {
+	rule := v.rules.TextFit
+	if rule.Severity == SeverityOff {
+		return
+	}
+	region := v.r.layout.Regions[RegionTitle]
+	_, face, err := v.r.measureFace(region)
+	if err != nil {
+		v.report(RuleTextFit, rule.Severity, "title", "%v", err)
+		return
+	}
+	room := region.Width - 2*region.Padding
+	for _, line := range splitTitle(title) {
+		if width := textWidth(face, line); width > room {
+			v.report(RuleTextFit, rule.Severity, "title", "%q is %dpx wide, the box is %dpx", line, width, room)
+		}
+	}
+}
+
+// text wraps text as drawText does and checks that every line fits the
+// width of the region and, when it has a height, that the lines fit it.
+func (v *validation) text(name, text string) {
+	rule := v.rules.TextFit
+	if rule.Severity == SeverityOff {
+		return
+	}
+	region := v.r.layout.Regions[name]
+	_, face, err := v.r.measureFace(region)
+	if err != nil {
+		v.report(RuleTextFit, rule.Severity, name, "%v", err)
+		return
+	}
+	room := region.Width - 2*region.Padding
+	lines := splitTextIntoLines(face, text, room)
+	for _, line := range lines {
+		if width := textWidth(face, line); width > room {
+			v.report(RuleTextFit, rule.Severity, name, "%q is %dpx wide, the box is %dpx", line, width, room)
+		}
+	}
+	if region.Height > 0 && region.LineHeight > 0 {
+		if fit := region.Height/region.LineHeight + 1; len(lines) > fit {
+			v.report(RuleTextFit, rule.Severity, name, "%d lines, the box has room for %d", len(lines), fit)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package sejm
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// newValidateRenderer draws with art that is missing for broken/missing.png
+// and isn't an image for broken/garbage.png.
+func newValidateRenderer() *Renderer {
+	return NewRenderer(Config{OpenArt: func(path string) (io.ReadCloser, error) {
+		switch path {
+		case "broken/missing.png":
+			return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+		case "broken/garbage.png":
+			return io.NopCloser(strings.NewReader("not an image")), nil
+		}
+		return openTestArt(path)
+	}})
+}
+
+// problemKeys lists problems as "severity rule field".
+func problemKeys(problems []Problem) []string {
+	keys := []string{}
+	for _, problem := range problems {
+		keys = append(keys, string(problem.Severity)+" "+problem.Rule+" "+problem.Field)
+	}
+	return keys
+}
+
+func TestValidate(t *testing.T) {
+	renderer := newValidateRenderer()
+	opinions := func(values ...Opinion) []Opinion {
+		return append(values, make([]Opinion, 10-len(values))...)
+	}
+	effects := func(values ...int) []int {
+		return append(values, make([]int, 7-len(values))...)
+	}
+	legislation := func(opinions []Opinion, effects []int, cost int) LegislationCard {
+		return NewLegislationCard("art/a", "Ustawa", opinions, effects, cost)
+	}
+	action := func(cost int, currency Currency) ActionCard {
+		return NewActionCard("art/b", "Akcja", "Opis", NoSymbol, cost, currency, "")
+	}
+	allEffects := effects(1, 1, 1, 1, 1, 1, 1)
+	if max := renderer.DefaultRules().EffectRange.MaxEffects; max >= len(allEffects) {
+		t.Fatalf("the layout has room for %d effects, the test wants fewer than %d", max, len(allEffects))
+	}
+
+	tests := []struct {
+		name  string
+		card  interface{}
+		rules string
+		want  []string
+	}{
+		{"test deck", testDeck[0], "", nil},
+		{"test deck", testDeck[1], "", nil},
+		{"test deck", testDeck[2], "", nil},
+		{"test deck", testDeck[3], "", nil},
+		{"not of the game", LegislationCard{ArtPath: "art/a.png", Opinions: make([]Opinion, 3), Effects: make([]int, 7)}, "",
+			[]string{"error card "}},
+
+		{"opinion past extra", legislation(opinions(3, 0, -3), effects(), 1), "",
+			[]string{"error opinion_range opinions.kat", "error opinion_range opinions.soc"}},
+		{"opinion range set", legislation(opinions(2), effects(), 1), "opinion_range: {min: -1, max: 1}",
+			[]string{"error opinion_range opinions.kat"}},
+		{"five for", legislation(opinions(1, 1, 1, 1, 2), effects(), 1), "", []string{"error stamp_slots opinions"}},
+		{"five against", legislation(opinions(-1, -1, -1, -1, -2), effects(), 1), "", []string{"error stamp_slots opinions"}},
+		{"stamps set", legislation(opinions(1, 1, -1), effects(), 1), "stamp_slots: {max_for: 1, max_against: 0}",
+			[]string{"error stamp_slots opinions", "error stamp_slots opinions"}},
+
+		{"effect points", legislation(opinions(), effects(4, -4, 3), 1), "",
+			[]string{"error effect_range effects.dochod", "error effect_range effects.zatrudnienie"}},
+		{"effect count", legislation(opinions(), allEffects, 1), "", []string{"error effect_range effects"}},
+		{"effects unlimited", legislation(opinions(), effects(9), 1), "effect_range: {max_points: 0, max_effects: 0}", nil},
+
+		{"cost past badges", action(6, "cash"), "", []string{"error cost_range cost"}},
+		{"cost under badges", action(-6, "trust"), "", []string{"error cost_range cost"}},
+		{"free", action(0, ""), "cost_range: {min: 1, max: 2}", nil},
+		{"cost without badge", action(9, "cash"), "cost_range: {max: 9}", []string{"error cost_range cost"}},
+
+		{"legislation currency", LegislationCard{ArtPath: "art/a.png", Title: "T", Opinions: opinions(), Effects: effects(), Cost: Cost{1, "trust"}}, "",
+			[]string{"error currency cost"}},
+		{"action currencies set", action(1, "trust"), "currency: {action: [cash, scandal]}", []string{"error currency cost"}},
+		{"currency by name", action(1, "Gotówka"), "currency: {action: [cash]}", nil},
+		{"rules currency by name", LegislationCard{ArtPath: "art/a.png", Title: "T", Opinions: opinions(), Effects: effects(), Cost: Cost{1, "trust"}},
+			"currency: {legislation: [Zaufanie]}", nil},
+
+		{"missing art", NewActionCard("broken/missing", "T", "", NoSymbol, 0, "", ""), "", []string{"error art art"}},
+		{"art not an image", NewActionCard("broken/garbage", "T", "", NoSymbol, 0, "", ""), "", []string{"error art art"}},
+		{"art off", NewActionCard("broken/missing", "T", "", NoSymbol, 0, "", ""), "art: {severity: off}", nil},
+
+		{"long title", NewActionCard("art/b", strings.Repeat("Bardzo długi tytuł ", 6), "", NoSymbol, 0, "", ""), "",
+			[]string{"warning text_fit title", "warning text_fit title"}},
+		{"long description", NewActionCard("art/b", "T", strings.Repeat("Bardzo długi opis akcji. ", 40), NoSymbol, 0, "", ""), "",
+			[]string{"warning text_fit description"}},
+		{"long red text", NewActionCard("art/b", "T", "", NoSymbol, 0, "", strings.Repeat("Czerwony tekst. ", 40)), "",
+			[]string{"warning text_fit red_text"}},
+		{"text fit is an error", NewActionCard("art/b", strings.Repeat("Bardzo długi tytuł ", 6), "", NoSymbol, 0, "", ""),
+			"text_fit: {severity: error}", []string{"error text_fit title", "error text_fit title"}},
+
+		{"warning", action(6, "cash"), "cost_range: {severity: warning}", []string{"warning cost_range cost"}},
+		{"off", legislation(opinions(3, 1, 1, 1, 1), effects(4), 1), "{opinion_range: {severity: off}, stamp_slots: {severity: off}, effect_range: {severity: off}}", nil},
+	}
+	for _, test := range tests {
+		rules, err := renderer.ReadRules(strings.NewReader(test.rules))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		problems := renderer.Validate(test.card, rules)
+		if got, want := strings.Join(problemKeys(problems), ", "), strings.Join(test.want, ", "); got != want {
+			t.Errorf("%s: problems %v, want %s", test.name, problems, want)
+		}
+	}
+}
+
+func TestValidateDefaultRules(t *testing.T) {
+	renderer := newValidateRenderer()
+	card := NewActionCard("art/b", "Akcja", "Opis", NoSymbol, 6, "cash", "")
+	if got, want := problemKeys(renderer.Validate(card, nil)), problemKeys(renderer.Validate(card, renderer.DefaultRules())); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Validate with nil rules: %v, want %v", got, want)
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	renderer := newValidateRenderer()
+	tests := []struct {
+		kind  CardKind
+		code  string
+		rules string
+		want  []string
+	}{
+		{KindLegislation, "a>T>()>()>1", "", []string{}},
+		{KindLegislation, "a>T>()>()>1>stare", "", []string{"warning extra_fields code"}},
+		{KindAction, "a>T>D>none>>0>Czerwony", "", []string{}},
+		{KindAction, "a>T>D>none>>0>Czerwony>x", "extra_fields: {severity: error}", []string{"error extra_fields code"}},
+		{KindAction, "a>T>D>none>>0>Czerwony>x", "extra_fields: {severity: off}", []string{}},
+	}
+	for _, test := range tests {
+		rules, err := renderer.ReadRules(strings.NewReader(test.rules))
+		if err != nil {
+			t.Fatal(err)
+		}
+		problems := renderer.ValidateCode(test.kind, test.code, rules)
+		if got, want := strings.Join(problemKeys(problems), ", "), strings.Join(test.want, ", "); got != want {
+			t.Errorf("ValidateCode(%q): problems %v, want %s", test.code, problems, want)
+		}
+	}
+
+	problems := renderer.ValidateCode(KindLegislation, "ą>T>()>()>1>stare", nil)
+	if len(problems) != 1 || !strings.HasPrefix(problems[0].Message, `column 13: "stare"`) {
+		t.Errorf("ValidateCode: %v, want the extra field at column 13", problems)
+	}
+}
+
+func TestReadRules(t *testing.T) {
+	renderer := newValidateRenderer()
+	rules, err := renderer.ReadRules(strings.NewReader("cost_range: {severity: warning, min: -3, max: 3}\ncurrency: {legislation: [Zaufanie]}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := renderer.DefaultRules()
+	if rules.CostRange != (RangeRule{Rule{SeverityWarning}, -3, 3}) {
+		t.Errorf("cost_range read as %+v", rules.CostRange)
+	}
+	if len(rules.Currency.Legislation) != 1 || rules.Currency.Legislation[0] != "trust" || rules.Currency.Severity != SeverityError {
+		t.Errorf("currency read as %+v", rules.Currency)
+	}
+	if rules.OpinionRange != defaults.OpinionRange || rules.StampSlots != defaults.StampSlots || rules.TextFit != defaults.TextFit {
+		t.Errorf("rules not in the file changed from the defaults: %+v", rules)
+	}
+
+	tests := []struct {
+		rules string
+		err   string
+	}{
+		{"cost_range: {maximum: 3}", "field maximum not found"},
+		{"colour: red", "field colour not found"},
+		{"cost_range: [1, 2]", "reading rules"},
+		{"art: {severity: fatal}", `art.severity: "fatal" is not error, warning or off`},
+		{"opinion_range: {min: 2, max: 1}", "opinion_range: min 2 is more than max 1"},
+		{"cost_range: {min: 1, max: -1}", "cost_range: min 1 is more than max -1"},
+		{"stamp_slots: {max_for: 5}", "stamp_slots.max_for: must be from 0 to the 4 stamps"},
+		{"stamp_slots: {max_against: -1}", "stamp_slots.max_against"},
+		{"effect_range: {max_points: -1}", "effect_range: max_points and max_effects can't be negative"},
+		{"currency: {action: [euro]}", `currency: "euro" is not one of`},
+	}
+	for _, test := range tests {
+		if _, err := renderer.ReadRules(strings.NewReader(test.rules)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ReadRules(%q): error %v, want one about %s", test.rules, err, test.err)
+		}
+	}
+
+	if _, err := renderer.LoadRules("missing.yaml"); err == nil {
+		t.Errorf("LoadRules of a missing file: no error")
+	}
+	var empty bytes.Buffer
+	if rules, err := renderer.ReadRules(&empty); err != nil || rules.CostRange != defaults.CostRange {
+		t.Errorf("ReadRules of nothing = %+v, %v, want the defaults", rules, err)
+	}
+}
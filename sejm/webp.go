@@ -0,0 +1,380 @@
+package sejm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// WebP has no encoder in the image libraries, so cards are written as
+// lossless WebP (VP8L) here. The encoder keeps to the parts of the format
+// that matter for cards: the subtract green transform, which makes the
+// red and blue of flat colours cheap, backward references to the pixel on
+// the left and the one above, which cover the flat areas, and one set of
+// prefix codes for the whole image. Files come out larger than a full
+// encoder would make them.
+
+const (
+	webpMaxSize          = 1 << 14
+	webpMaxCodeLength    = 15
+	webpMaxLengthCodeLen = 7
+	webpGreenAlphabet    = 256 + 24 // literals and backward reference lengths
+	webpDistanceAlphabet = 40
+	webpMinCopy          = 3
+	webpMaxCopy          = 4096
+)
+
+// Distance codes of the neighbouring pixels
+const (
+	webpAbove = 1
+	webpLeft  = 2
+)
+
+// webpToken is a literal pixel, as green, red - green, blue - green and
+// alpha, or a copy of length pixels from a distance code.
+type webpToken struct {
+	pixel          [4]uint8
+	length, offset int
+}
+
+// webpCodeLengthOrder is the order code length code lengths are written in.
+var webpCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// EncodeWebP writes a rendered card as a lossless WebP.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > webpMaxSize || height > webpMaxSize {
+		return fmt.Errorf("webp: %dx%d is not between 1x1 and %dx%d", width, height, webpMaxSize, webpMaxSize)
+	}
+
+	argb := make([]uint32, 0, width*height)
+	alpha := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			argb = append(argb, uint32(c.A)<<24|uint32(c.R)<<16|uint32(c.G)<<8|uint32(c.B))
+			alpha = alpha || c.A != 0xff
+		}
+	}
+
+	tokens := webpTokens(argb, width)
+	var histograms [5][]int
+	for i := range histograms {
+		histograms[i] = make([]int, 256)
+	}
+	histograms[0] = make([]int, webpGreenAlphabet)
+	histograms[4] = make([]int, webpDistanceAlphabet)
+	for _, token := range tokens {
+		if token.length == 0 {
+			for i, value := range token.pixel {
+				histograms[i][value]++
+			}
+			continue
+		}
+		lengthPrefix, _, _ := webpPrefix(token.length)
+		histograms[0][256+lengthPrefix]++
+		distancePrefix, _, _ := webpPrefix(token.offset)
+		histograms[4][distancePrefix]++
+	}
+
+	var bits webpBitWriter
+	bits.write(0x2f, 8)
+	bits.write(uint32(width-1), 14)
+	bits.write(uint32(height-1), 14)
+	if alpha {
+		bits.write(1, 1)
+	} else {
+		bits.write(0, 1)
+	}
+	bits.write(0, 3) // version
+
+	bits.write(1, 1) // a transform follows
+	bits.write(2, 2) // subtract green
+	bits.write(0, 1) // no more transforms
+	bits.write(0, 1) // no colour cache
+	bits.write(0, 1) // one set of prefix codes
+
+	var codes [5]webpCode
+	for i, histogram := range histograms {
+		codes[i] = bits.writeCode(histogram)
+	}
+
+	for _, token := range tokens {
+		if token.length == 0 {
+			for i, value := range token.pixel {
+				codes[i].write(&bits, int(value))
+			}
+			continue
+		}
+		prefix, extraBits, extra := webpPrefix(token.length)
+		codes[0].write(&bits, 256+prefix)
+		bits.write(extra, extraBits)
+		prefix, extraBits, extra = webpPrefix(token.offset)
+		codes[4].write(&bits, prefix)
+		bits.write(extra, extraBits)
+	}
+	data := bits.bytes()
+
+	var out bytes.Buffer
+	size := len(data) + len(data)%2
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(4+8+size))
+	out.WriteString("WEBPVP8L")
+	binary.Write(&out, binary.LittleEndian, uint32(len(data)))
+	out.Write(data)
+	if len(data)%2 == 1 {
+		out.WriteByte(0)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// webpTokens splits the pixels into literals and copies of the pixel on
+// the left or the one above, whichever copies more.
+func webpTokens(argb []uint32, width int) []webpToken {
+	var tokens []webpToken
+	for i := 0; i < len(argb); {
+		best, offset := 0, 0
+		for _, candidate := range []struct{ distance, offset int }{{1, webpLeft}, {width, webpAbove}} {
+			if i < candidate.distance {
+				continue
+			}
+			n := 0
+			for n < webpMaxCopy && i+n < len(argb) && argb[i+n] == argb[i+n-candidate.distance] {
+				n++
+			}
+			if n > best {
+				best, offset = n, candidate.offset
+			}
+		}
+
+		if best >= webpMinCopy {
+			tokens = append(tokens, webpToken{length: best, offset: offset})
+			i += best
+			continue
+		}
+		a, r, g, b := uint8(argb[i]>>24), uint8(argb[i]>>16), uint8(argb[i]>>8), uint8(argb[i])
+		tokens = append(tokens, webpToken{pixel: [4]uint8{g, r - g, b - g, a}})
+		i++
+	}
+	return tokens
+}
+
+// webpPrefix splits a length or distance code into its prefix symbol and
+// the extra bits that follow it.
+func webpPrefix(value int) (prefix int, extraBits uint, extra uint32) {
+	value--
+	if value < 4 {
+		return value, 0, 0
+	}
+	highest := 0
+	for value>>(highest+1) != 0 {
+		highest++
+	}
+	second := value >> (highest - 1) & 1
+	extraBits = uint(highest - 1)
+	return 2*highest + second, extraBits, uint32(value) & (1<<extraBits - 1)
+}
+
+// webpBitWriter packs values least significant bit first.
+type webpBitWriter struct {
+	buf   []byte
+	acc   uint64
+	count uint
+}
+
+func (b *webpBitWriter) write(value uint32, n uint) {
+	b.acc |= uint64(value) << b.count
+	b.count += n
+	for b.count >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.count -= 8
+	}
+}
+
+func (b *webpBitWriter) bytes() []byte {
+	if b.count > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.count = 0, 0
+	}
+	return b.buf
+}
+
+// webpCode is a prefix code by symbol. Codes are stored bit reversed, ready
+// to be written least significant bit first.
+type webpCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+func (c *webpCode) write(b *webpBitWriter, symbol int) {
+	b.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writeCode writes the prefix code for a histogram and returns it. Codes of
+// one or two symbols below 256 take the short form.
+func (b *webpBitWriter) writeCode(histogram []int) webpCode {
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		code := webpCode{lengths: make([]int, len(histogram)), codes: make([]uint32, len(histogram))}
+		b.write(1, 1)
+		b.write(uint32(len(used)-1), 1)
+		b.write(1, 1) // 8 bit symbols
+		for i, symbol := range used {
+			b.write(uint32(symbol), 8)
+			if len(used) == 2 {
+				code.lengths[symbol] = 1
+				code.codes[symbol] = uint32(i)
+			}
+		}
+		return code
+	}
+
+	lengths := webpCodeLengths(histogram, webpMaxCodeLength)
+	code := webpCanonicalCode(lengths)
+
+	// The code lengths are written with a code of their own, symbols 0 to
+	// 15 being the lengths themselves. It needs two symbols to be complete.
+	lengthHistogram := make([]int, len(webpCodeLengthOrder))
+	for _, length := range lengths {
+		lengthHistogram[length]++
+	}
+	if nonZero(lengthHistogram) < 2 {
+		if lengthHistogram[0] == 0 {
+			lengthHistogram[0] = 1
+		} else {
+			lengthHistogram[1] = 1
+		}
+	}
+	lengthCode := webpCanonicalCode(webpCodeLengths(lengthHistogram, webpMaxLengthCodeLen))
+
+	count := len(webpCodeLengthOrder)
+	for count > 4 && lengthCode.lengths[webpCodeLengthOrder[count-1]] == 0 {
+		count--
+	}
+	b.write(0, 1)
+	b.write(uint32(count-4), 4)
+	for _, symbol := range webpCodeLengthOrder[:count] {
+		b.write(uint32(lengthCode.lengths[symbol]), 3)
+	}
+	b.write(0, 1) // lengths for the whole alphabet
+	for _, length := range lengths {
+		lengthCode.write(b, length)
+	}
+	return code
+}
+
+func nonZero(counts []int) int {
+	n := 0
+	for _, count := range counts {
+		if count > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// webpCodeLengths returns Huffman code lengths of at most limit bits for
+// the histogram. Counts are flattened until the longest code fits.
+func webpCodeLengths(histogram []int, limit int) []int {
+	counts := append([]int(nil), histogram...)
+	for {
+		lengths := huffmanLengths(counts)
+		longest := 0
+		for _, length := range lengths {
+			longest = max(longest, length)
+		}
+		if longest <= limit {
+			return lengths
+		}
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = count/2 + 1
+			}
+		}
+	}
+}
+
+// huffmanLengths returns the depth of every symbol in a Huffman tree of the
+// counts, 0 for symbols that don't occur.
+func huffmanLengths(counts []int) []int {
+	type node struct {
+		count       int
+		symbol      int // -1 for inner nodes
+		left, right int
+	}
+	var nodes []node
+	var queue []int
+	for symbol, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, node{count: count, symbol: symbol})
+			queue = append(queue, len(nodes)-1)
+		}
+	}
+	lengths := make([]int, len(counts))
+	if len(queue) == 1 {
+		lengths[nodes[0].symbol] = 1
+		return lengths
+	}
+
+	for len(queue) > 1 {
+		sort.SliceStable(queue, func(i, j int) bool { return nodes[queue[i]].count < nodes[queue[j]].count })
+		nodes = append(nodes, node{count: nodes[queue[0]].count + nodes[queue[1]].count, symbol: -1, left: queue[0], right: queue[1]})
+		queue = append(queue[2:], len(nodes)-1)
+	}
+
+	var walk func(i, depth int)
+	walk = func(i, depth int) {
+		if nodes[i].symbol >= 0 {
+			lengths[nodes[i].symbol] = depth
+			return
+		}
+		walk(nodes[i].left, depth+1)
+		walk(nodes[i].right, depth+1)
+	}
+	walk(queue[0], 0)
+	return lengths
+}
+
+// webpCanonicalCode numbers the codes of the lengths in order of length,
+// then symbol, as decoders rebuild them.
+func webpCanonicalCode(lengths []int) webpCode {
+	code := webpCode{lengths: lengths, codes: make([]uint32, len(lengths))}
+	var perLength [webpMaxCodeLength + 2]uint32
+	for _, length := range lengths {
+		perLength[length]++
+	}
+	perLength[0] = 0
+	var next [webpMaxCodeLength + 2]uint32
+	for length := 1; length <= webpMaxCodeLength; length++ {
+		next[length+1] = (next[length] + perLength[length]) << 1
+	}
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		value := next[length]
+		next[length]++
+		var reversed uint32
+		for i := 0; i < length; i++ {
+			reversed = reversed<<1 | value>>i&1
+		}
+		code.codes[symbol] = reversed
+	}
+	return code
+}
@@ -0,0 +1,131 @@
+package sejm
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	flat := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for i := range flat.Pix {
+		flat.Pix[i] = []uint8{0x3a, 0x3a, 0x3a, 0xff}[i%4]
+	}
+
+	noise := image.NewNRGBA(image.Rect(0, 0, 97, 61))
+	rng := rand.New(rand.NewSource(1))
+	rng.Read(noise.Pix)
+
+	stripes := image.NewNRGBA(image.Rect(10, 20, 143, 77))
+	for y := 20; y < 77; y++ {
+		for x := 10; x < 143; x++ {
+			stripes.Set(x, y, color.NRGBA{uint8(x / 7 * 40), uint8(y % 3 * 100), 0xff, uint8(0xff - y)})
+		}
+	}
+
+	// Every channel but green takes a single value, so their codes take
+	// the short form of one symbol
+	greens := image.NewNRGBA(image.Rect(0, 0, 40, 3))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 3; y++ {
+			greens.Set(x, y, color.NRGBA{0x10, uint8(x * 6), 0x20, 0xff})
+		}
+	}
+
+	// Transparent pixels keep their colour
+	transparent := image.NewNRGBA(image.Rect(0, 0, 30, 20))
+	for i := range transparent.Pix {
+		transparent.Pix[i] = []uint8{uint8(i), 0x80, 0xff, 0}[i%4]
+	}
+
+	red := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	red.Set(0, 0, color.NRGBA{0xff, 0, 0, 0xff})
+
+	// The largest sizes a VP8L header holds
+	tallest := image.NewNRGBA(image.Rect(0, 0, 1, 1<<14))
+	for y := 0; y < 1<<14; y++ {
+		tallest.Set(0, y, color.NRGBA{uint8(y), uint8(y >> 8), uint8(y * 7), 0xff})
+	}
+
+	card, err := newTestRenderer().Render(context.Background(), testDeck[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"single pixel", image.NewNRGBA(image.Rect(0, 0, 1, 1))},
+		{"single red pixel", red},
+		{"one value per channel but green", greens},
+		{"fully transparent", image.NewNRGBA(image.Rect(0, 0, 50, 50))},
+		{"fully transparent with colours", transparent},
+		{"widest", image.NewNRGBA(image.Rect(0, 0, 1<<14, 1))},
+		{"tallest", tallest},
+		{"flat", flat},
+		{"noise with alpha", noise},
+		{"stripes off the origin", stripes},
+		{"gradient", testImage()},
+		{"card", card},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := EncodeWebP(&b, test.img); err != nil {
+			t.Errorf("%s: EncodeWebP: %v", test.name, err)
+			continue
+		}
+		decoded, err := webp.Decode(&b)
+		if err != nil {
+			t.Errorf("%s: webp.Decode: %v", test.name, err)
+			continue
+		}
+
+		bounds := test.img.Bounds()
+		if decoded.Bounds().Size() != bounds.Size() {
+			t.Errorf("%s: decoded %v, want %v", test.name, decoded.Bounds().Size(), bounds.Size())
+			continue
+		}
+	pixels:
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				want := color.NRGBAModel.Convert(test.img.At(bounds.Min.X+x, bounds.Min.Y+y))
+				got := color.NRGBAModel.Convert(decoded.At(x, y))
+				if got != want {
+					t.Errorf("%s: pixel %d,%d is %v, want %v", test.name, x, y, got, want)
+					break pixels
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeWebPSize(t *testing.T) {
+	for _, size := range []image.Rectangle{
+		image.Rect(0, 0, 0, 5),
+		image.Rect(0, 0, 5, 0),
+		image.Rect(0, 0, 1<<14+1, 1),
+		image.Rect(0, 0, 1, 1<<14+1),
+	} {
+		if err := EncodeWebP(&bytes.Buffer{}, image.NewNRGBA(size)); err == nil {
+			t.Errorf("EncodeWebP of %v: no error", size)
+		}
+	}
+}
+
+func TestHuffmanLengthsSingleSymbol(t *testing.T) {
+	counts := make([]int, webpGreenAlphabet)
+	counts[260] = 9
+	lengths := webpCodeLengths(counts, webpMaxCodeLength)
+	want := make([]int, webpGreenAlphabet)
+	want[260] = 1
+	if !reflect.DeepEqual(lengths, want) {
+		t.Errorf("lengths of a single symbol %v, want 1 for it", lengths)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nfnt/resize"
+
+	"sejm_generator/sejm"
+)
+
+//go:embed serve.html
+var editorPage []byte
+
+// maxCardRequest bounds the body of a card sent by the editor.
+const maxCardRequest = 64 << 10
+
+// editor serves the card editor of the serve command. Cards are saved to
+// deck, when one is given, and drawn into the output directory.
+type editor struct {
+	opts *options
+	deck string
+
+	mu sync.Mutex // held while the deck file is rewritten
+}
+
+// editorGame is the vocabulary the editor's form is built from, with the
+// deck cards are saved to. Icons are served under /assets/.
+type editorGame struct {
+	vocabulary
+	Deck string `json:"deck,omitempty"`
+}
+
+func (e *editor) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", e.page)
+	mux.HandleFunc("GET /game", e.game)
+	mux.HandleFunc("GET /cards", e.cards)
+	mux.HandleFunc("GET /assets/{name...}", e.asset)
+	mux.HandleFunc("POST /preview", e.preview)
+	mux.HandleFunc("POST /download", e.download)
+	mux.HandleFunc("POST /save", e.save)
+	return mux
+}
+
+func (e *editor) page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(editorPage)
+}
+
+func (e *editor) game(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, editorGame{newVocabulary(game), e.deck})
+}
+
+// cards lists the cards of the deck, so that the editor can open them.
+func (e *editor) cards(w http.ResponseWriter, r *http.Request) {
+	defs := []CardDef{}
+	if e.deck != "" {
+		cards, errs := LoadDeck(e.deck)
+		if len(errs) > 0 && !errors.Is(errs[0], os.ErrNotExist) {
+			writeError(w, http.StatusConflict, errs[0])
+			return
+		}
+		for _, card := range cards {
+			def := NewCardDef(card.Card)
+			def.Copies = card.Copies
+			defs = append(defs, def)
+		}
+	}
+	writeJSON(w, http.StatusOK, defs)
+}
+
+func (e *editor) asset(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, assetSource, r.PathValue("name"))
+}
+
+// card reads the card of a request, in the form of JSON and YAML decks.
+func (e *editor) card(r *http.Request) (CardDef, interface{}, error) {
+	var def CardDef
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxCardRequest))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&def); err != nil {
+		return def, nil, fmt.Errorf("reading card: %v", err)
+	}
+	if def.Copies < 0 {
+		return def, nil, &sejm.ParseError{Field: "copies", Value: strconv.Itoa(def.Copies), Reason: "must be at least 1"}
+	}
+	card, err := def.Card()
+	if err == nil {
+		err = game.CheckCard(card)
+	}
+	return def, card, err
+}
+
+// preview draws the card scaled down to the width asked for, quickly
+// rather than small, as it is redrawn on every edit.
+func (e *editor) preview(w http.ResponseWriter, r *http.Request) {
+	_, card, err := e.card(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	img, err := renderer.Render(r.Context(), card)
+	if err != nil {
+		writeRenderError(w, r, err)
+		return
+	}
+	if width, err := strconv.Atoi(r.URL.Query().Get("width")); err == nil && width > 0 && width < img.Bounds().Dx() {
+		img = resize.Resize(uint(width), 0, img, resize.Bilinear)
+	}
+
+	var out bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(&out, img); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(out.Bytes())
+}
+
+// download draws the card as the render command would.
+func (e *editor) download(w http.ResponseWriter, r *http.Request) {
+	_, card, err := e.card(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	img, err := renderer.Render(r.Context(), card)
+	if err != nil {
+		writeRenderError(w, r, err)
+		return
+	}
+
+	name := outputName(filepath.Base(cardArt(card)))
+	var out bytes.Buffer
+	if err := encodeImage(&out, img, name, sejm.NewCardMetadata(card, Version)); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if strings.HasSuffix(name, ".jpg") {
+		w.Header().Set("Content-Type", "image/jpeg")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Write(out.Bytes())
+}
+
+// save draws the card into the output directory and puts it into the
+// deck, in place of the card with the same art if there is one.
+func (e *editor) save(w http.ResponseWriter, r *http.Request) {
+	if e.deck == "" {
+		writeError(w, http.StatusConflict, fmt.Errorf("no deck file to save to, start serve with one"))
+		return
+	}
+	def, card, err := e.card(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Drawn first, so that cards that can't be drawn stay out of the deck
+	if err := os.MkdirAll(e.opts.OutDir, 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	output, err := renderCard(card, e.opts.OutDir)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	e.mu.Lock()
+	replaced, err := saveCard(e.deck, card, def.Copies, e.opts.Named)
+	e.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+
+	verb := "Added"
+	if replaced {
+		verb = "Updated"
+	}
+	message := fmt.Sprintf("%s %s in %s -> %s", verb, cardArt(card), e.deck, output)
+	e.opts.printf(normal, "%s\n", message)
+	writeJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
+// saveCard writes card into a deck file, replacing the card drawn from the
+// same art. Decks that don't exist yet are created. Card code decks keep
+// their other lines as they are.
+func saveCard(path string, card interface{}, copies int, named bool) (replaced bool, err error) {
+	if spreadsheetSeparator(path) != 0 {
+		return false, fmt.Errorf("%s: spreadsheet decks can't be edited, convert it to JSON or YAML", path)
+	}
+	cards, errs := LoadDeck(path)
+	if len(errs) == 1 && errors.Is(errs[0], os.ErrNotExist) {
+		cards, errs = nil, nil
+	}
+	if len(errs) > 0 {
+		return false, fmt.Errorf("%v: fix the deck before saving to it", errs[0])
+	}
+
+	line := 0
+	for i, existing := range cards {
+		if cardArt(existing.Card) == cardArt(card) {
+			line = existing.Line
+			cards[i] = DeckCard{Line: line, Card: card, Copies: copies}
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		cards = append(cards, DeckCard{Card: card, Copies: copies})
+	}
+
+	var out bytes.Buffer
+	if format := structuredFormat(path); format != "" {
+		if err := WriteStructuredDeck(&out, cards, format); err != nil {
+			return false, err
+		}
+		return replaced, writeFileAtomic(path, out.Bytes())
+	}
+
+	if copies > 1 {
+		return false, fmt.Errorf("%s: card code decks can't hold copies, convert it to JSON or YAML", path)
+	}
+	code, err := game.MarshalCard(card, named)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if replaced {
+		lines[line-1] = code + "\n"
+	} else {
+		if last := lines[len(lines)-1]; last != "" {
+			lines[len(lines)-1] = last + "\n"
+		}
+		lines = append(lines, code+"\n")
+	}
+	return replaced, writeFileAtomic(path, []byte(strings.Join(lines, "")))
+}
+
+// writeRenderError reports a card that failed to draw, unless the editor
+// gave up on it for a newer edit.
+func writeRenderError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		return
+	}
+	writeError(w, http.StatusUnprocessableEntity, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// serveEditor runs the card editor on addr until the server fails.
+func serveEditor(opts *options, deck string) int {
+	e := &editor{opts: opts, deck: deck}
+	server := &http.Server{Addr: opts.Addr, Handler: e.routes()}
+
+	fmt.Printf("Card editor on http://%s/\n", displayAddr(opts.Addr))
+	if deck != "" {
+		fmt.Printf("Saving cards to %s\n", deck)
+	}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// displayAddr returns an address to open in a browser for a listening
+// address, which may leave out the host.
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
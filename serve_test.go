@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sejm_generator/sejm"
+)
+
+// editorRequest posts body to the editor and returns the response.
+func editorRequest(e *editor, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.routes().ServeHTTP(w, httptest.NewRequest("POST", target, strings.NewReader(body)))
+	return w
+}
+
+const editorCard = `{"type": "action", "art": "a", "title": "Akcja", "description": "Opis", "cost": {"value": 2, "currency": "cash"}}`
+
+func TestEditorPreview(t *testing.T) {
+	chdirTemp(t)
+	writeTestArt(t, "a.png")
+	e := &editor{opts: &options{}}
+
+	w := editorRequest(e, "/preview?width=200", editorCard)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("preview: %d %s", w.Code, w.Body)
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 200 {
+		t.Errorf("preview is %d pixels wide, want 200", img.Bounds().Dx())
+	}
+
+	tests := []struct {
+		body   string
+		status int
+		err    string
+	}{
+		{`{"type": "action",`, http.StatusBadRequest, "reading card"},
+		{`{"type": "action", "colour": "red"}`, http.StatusBadRequest, "unknown field"},
+		{`{"type": "action", "art": "a", "title": "T", "copies": -1}`, http.StatusBadRequest, "copies"},
+		{`{"type": "action", "art": "a", "title": "T", "symbol": "kapelusz"}`, http.StatusBadRequest, "symbol"},
+		{`{"type": "action", "art": "b", "title": "T"}`, http.StatusUnprocessableEntity, "art"},
+	}
+	for _, test := range tests {
+		w := editorRequest(e, "/preview", test.body)
+		var body map[string]string
+		json.NewDecoder(w.Body).Decode(&body)
+		if w.Code != test.status || !strings.Contains(body["error"], test.err) {
+			t.Errorf("preview of %s: %d %q, want %d and an error about %s", test.body, w.Code, body["error"], test.status, test.err)
+		}
+	}
+}
+
+func TestEditorDownload(t *testing.T) {
+	chdirTemp(t)
+	writeTestArt(t, "a.png")
+	w := editorRequest(&editor{opts: &options{}}, "/download", editorCard)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("download: %d %s", w.Code, w.Body)
+	}
+	if got, want := w.Header().Get("Content-Disposition"), `attachment; filename="a.png"`; got != want {
+		t.Errorf("Content-Disposition %s, want %s", got, want)
+	}
+	meta, err := sejm.ReadCardMetadata(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a>Akcja>Opis>none>cash>2"; meta.Kind != sejm.KindAction || meta.Code != want {
+		t.Errorf("downloaded card is %s: %s, want action: %s", meta.Kind, meta.Code, want)
+	}
+}
+
+func TestEditorSave(t *testing.T) {
+	chdirTemp(t)
+	writeTestArt(t, "a.png")
+
+	w := editorRequest(&editor{opts: &options{OutDir: "out"}}, "/save", editorCard)
+	if w.Code != http.StatusConflict {
+		t.Errorf("save without a deck: %d %s, want %d", w.Code, w.Body, http.StatusConflict)
+	}
+
+	e := &editor{opts: &options{OutDir: "out"}, deck: "deck.txt"}
+	for _, want := range []string{"Added a.png", "Updated a.png"} {
+		w := editorRequest(e, "/save", editorCard)
+		var body map[string]string
+		json.NewDecoder(w.Body).Decode(&body)
+		if w.Code != http.StatusOK || !strings.HasPrefix(body["message"], want) {
+			t.Errorf("save: %d %v, want a message starting with %s", w.Code, body, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("out", "a.png")); err != nil {
+		t.Errorf("the saved card wasn't drawn: %v", err)
+	}
+	if data, _ := os.ReadFile("deck.txt"); string(data) != "action: a>Akcja>Opis>none>cash>2\n" {
+		t.Errorf("deck is %q after saving the card twice", data)
+	}
+
+	// Cards that can't be drawn stay out of the deck
+	w = editorRequest(e, "/save", `{"type": "action", "art": "b", "title": "T"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("save of a card without art: %d %s, want %d", w.Code, w.Body, http.StatusUnprocessableEntity)
+	}
+	if data, _ := os.ReadFile("deck.txt"); strings.Contains(string(data), "b>") {
+		t.Errorf("a card that can't be drawn was saved: %q", data)
+	}
+}
+
+func TestSaveCard(t *testing.T) {
+	card := sejm.NewActionCard("a", "Nowa", "Opis", sejm.NoSymbol, 1, "cash", "")
+	tests := []struct {
+		name     string
+		path     string
+		deck     string // "" for no file
+		copies   int
+		replaced bool
+		want     string
+	}{
+		{"card code deck, replaced by art", "deck.txt",
+			"# Talia\naction: a>Stara>d>none>>0\n\nlegislation: b>T>()>()>1\n", 0, true,
+			"# Talia\naction: a>Nowa>Opis>none>cash>1\n\nlegislation: b>T>()>()>1\n"},
+		{"card code deck, added", "deck.txt",
+			"legislation: b>T>()>()>1", 0, false,
+			"legislation: b>T>()>()>1\naction: a>Nowa>Opis>none>cash>1\n"},
+		{"missing card code deck", "deck.txt", "", 0, false,
+			"action: a>Nowa>Opis>none>cash>1\n"},
+		{"YAML deck, replaced by art", "deck.yaml",
+			"cards:\n  - {type: legislation, art: b, title: T}\n  - {type: action, art: a, title: Stara, copies: 3}\n", 2, true,
+			"cards:\n  - type: legislation\n    art: b\n    title: T\n" +
+				"  - type: action\n    art: a\n    title: Nowa\n    description: Opis\n    cost:\n      value: 1\n      currency: cash\n    copies: 2\n"},
+		{"missing YAML deck", "deck.yaml", "", 0, false,
+			"cards:\n  - type: action\n    art: a\n    title: Nowa\n    description: Opis\n    cost:\n      value: 1\n      currency: cash\n"},
+	}
+	for _, test := range tests {
+		chdirTemp(t)
+		if test.deck != "" {
+			if err := os.WriteFile(test.path, []byte(test.deck), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		replaced, err := saveCard(test.path, card, test.copies, false)
+		if err != nil || replaced != test.replaced {
+			t.Errorf("%s: saveCard = %v, %v, want %v", test.name, replaced, err, test.replaced)
+			continue
+		}
+		if data, _ := os.ReadFile(test.path); string(data) != test.want {
+			t.Errorf("%s: deck is\n%s\nwant\n%s", test.name, data, test.want)
+		}
+	}
+}
+
+func TestSaveCardErrors(t *testing.T) {
+	card := sejm.NewActionCard("a", "Nowa", "Opis", sejm.NoSymbol, 1, "cash", "")
+	tests := []struct {
+		path   string
+		deck   string
+		copies int
+		err    string
+	}{
+		{"deck.csv", "type,art,title\naction,a,Stara\n", 0, "spreadsheet decks can't be edited"},
+		{"deck.tsv", "", 0, "spreadsheet decks can't be edited"},
+		{"deck.txt", "action: b>T>d>none>>0\n", 2, "card code decks can't hold copies"},
+		{"deck.txt", "action: b>T>d>kapelusz>>0\n", 0, "fix the deck before saving to it"},
+	}
+	for _, test := range tests {
+		chdirTemp(t)
+		if test.deck != "" {
+			if err := os.WriteFile(test.path, []byte(test.deck), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := saveCard(test.path, card, test.copies, false); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("saveCard to %s: error %v, want one about %s", test.path, err, test.err)
+		}
+		if data, _ := os.ReadFile(test.path); !bytes.Equal(data, []byte(test.deck)) {
+			t.Errorf("saveCard to %s changed the deck to %q", test.path, data)
+		}
+	}
+}
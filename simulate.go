@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"sejm_generator/sejm"
+)
+
+// deckBills returns the legislation of the decks, put to the vote as many
+// times as it is printed, and the deck cards they came from.
+func deckBills(cards []DeckCard, copies int) ([]sejm.Bill, []DeckCard) {
+	var bills []sejm.Bill
+	var sources []DeckCard
+	for _, card := range cards {
+		legislation, ok := card.Card.(sejm.LegislationCard)
+		if !ok {
+			continue
+		}
+		n := card.Copies
+		if n == 0 {
+			n = copies
+		}
+		bills = append(bills, sejm.Bill{Card: legislation, Copies: n})
+		sources = append(sources, card)
+	}
+	return bills, sources
+}
+
+// writeSimulationTable writes the chance every card passes and the drift
+// of the indicators.
+func writeSimulationTable(w io.Writer, model *sejm.VoteModel, bills []sejm.Bill, sim *sejm.Simulation) {
+	fmt.Fprintf(w, "%d games of %d seats, seed %d\n\n", sim.Games, model.TotalSeats(), sim.Seed)
+	fmt.Fprintf(w, "  %-40s %7s %8s\n", "card", "passes", "yes")
+	for i, bill := range bills {
+		fmt.Fprintf(w, "  %-40s %6.1f%% %8.1f\n", truncate(bill.Card.Title, 40), 100*sim.PassRate(i), sim.MeanYes(i))
+	}
+
+	fmt.Fprintf(w, "\nIndicator drift per game\n")
+	fmt.Fprintf(w, "  %-18s %8s %8s\n", "indicator", "mean", "std dev")
+	for i, indicator := range game.Indicators {
+		mean, deviation := sim.Drift(i)
+		fmt.Fprintf(w, "  %-18s %+8.2f %8.2f\n", indicator.Name, mean, deviation)
+	}
+}
+
+// truncate shortens text to at most n characters.
+func truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// writeSimulationCSV writes a row per card: where it is, its title, the
+// chance it passes and its average yes votes.
+func writeSimulationCSV(w io.Writer, cards []DeckCard, bills []sejm.Bill, sim *sejm.Simulation) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"art", "line", "title", "copies", "pass_rate", "mean_yes"})
+	for i, bill := range bills {
+		out.Write([]string{
+			bill.Card.ArtPath,
+			strconv.Itoa(cards[i].Line),
+			bill.Card.Title,
+			strconv.Itoa(bill.Copies),
+			strconv.FormatFloat(sim.PassRate(i), 'f', 4, 64),
+			strconv.FormatFloat(sim.MeanYes(i), 'f', 1, 64),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// simulateDecks votes on the legislation of the decks in many games and
+// reports how it went.
+func simulateDecks(opts *options, paths []string, model *sejm.VoteModel) int {
+	cards, failed := loadDecks(paths)
+	if failed {
+		fmt.Println("simulate: not voting on decks with missing cards")
+		return exitFailures
+	}
+	bills, sources := deckBills(cards, opts.Copies)
+	if len(bills) == 0 {
+		fmt.Println("simulate: the decks have no legislation cards")
+		return exitFailures
+	}
+
+	sim := model.Simulate(bills, opts.Runs, opts.Seed, opts.Workers)
+	writeSimulationTable(os.Stdout, model, bills, sim)
+	if opts.CSV != "" {
+		var out bytes.Buffer
+		if err := writeSimulationCSV(&out, sources, bills, sim); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+		if err := writeFileAtomic(opts.CSV, out.Bytes()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+		opts.printf(normal, "Wrote %s\n", opts.CSV)
+	}
+	return exitOK
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sejm_generator/sejm"
+)
+
+// Spreadsheet decks are CSV or TSV exports with one card per row. A column
+// mapping file (JSON or YAML) says which column fills which card field:
+//
+//	default_type: legislation
+//	columns:
+//	  Grafika: art
+//	  Tytuł: title
+//	  Katolicy: opinions[0]
+//	  Inflacja: effects.inflacja
+//	  Koszt: cost
+//
+// Field names are type, art, title, description, symbol, cost, currency,
+// red_text, copies, opinions[N] or opinions.<group> and effects[N] or
+// effects.<indicator>. Columns named after a field, a group or an indicator
+// are mapped without an entry, other columns are ignored.
+
+// SpreadsheetColumns is the column mapping file used for CSV and TSV decks.
+// When empty, a "<deck>.columns.yaml" file next to the deck is used if it
+// exists.
+var SpreadsheetColumns = ""
+
+type ColumnMapping struct {
+	DefaultType sejm.CardKind     `json:"default_type" yaml:"default_type"`
+	Columns     map[string]string `json:"columns" yaml:"columns"`
+}
+
+func LoadColumnMapping(path string) (ColumnMapping, error) {
+	var mapping ColumnMapping
+
+	file, err := os.Open(path)
+	if err != nil {
+		return mapping, err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&mapping); err != nil && err != io.EOF {
+		return mapping, fmt.Errorf("%s: %v", path, err)
+	}
+	return mapping, nil
+}
+
+// spreadsheetSeparator returns the field separator for CSV and TSV decks,
+// or 0 for other files.
+func spreadsheetSeparator(path string) rune {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ','
+	case ".tsv":
+		return '\t'
+	}
+	return 0
+}
+
+func mappingFor(deckPath string) (ColumnMapping, error) {
+	if SpreadsheetColumns != "" {
+		return LoadColumnMapping(SpreadsheetColumns)
+	}
+	sidecar := strings.TrimSuffix(deckPath, filepath.Ext(deckPath)) + ".columns.yaml"
+	if _, err := os.Stat(sidecar); err == nil {
+		return LoadColumnMapping(sidecar)
+	}
+	return ColumnMapping{}, nil
+}
+
+func LoadSpreadsheetDeck(path string) ([]DeckCard, []error) {
+	mapping, err := mappingFor(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer file.Close()
+
+	return ReadSpreadsheetDeck(file, path, spreadsheetSeparator(path), mapping)
+}
+
+// ReadSpreadsheetDeck reads one card per row after the header row. Errors
+// are reported per row so that one bad row doesn't hide the others.
+func ReadSpreadsheetDeck(r io.Reader, name string, separator rune, mapping ColumnMapping) ([]DeckCard, []error) {
+	reader := csv.NewReader(r)
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []error{fmt.Errorf("%s: %v", name, err)}
+	}
+
+	targets, err := mapping.targets(header)
+	if err != nil {
+		return nil, []error{&DeckError{name, 1, err}}
+	}
+
+	var cards []DeckCard
+	var errs []error
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// A row that doesn't parse has no fields to ask the position of
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return cards, append(errs, fmt.Errorf("%s: %v", name, err))
+			}
+			errs = append(errs, &DeckError{name, parseErr.StartLine, fmt.Errorf("column %d: %v", parseErr.Column, parseErr.Err)})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlankRow(row) || strings.HasPrefix(strings.TrimSpace(row[0]), "#") {
+			continue
+		}
+
+		def := CardDef{Type: mapping.DefaultType, Opinions: NamedValues{}, Effects: NamedValues{}}
+		var rowErr error
+		for i, cell := range row {
+			cell = strings.TrimSpace(cell)
+			if i >= len(targets) || targets[i] == "" || cell == "" {
+				continue
+			}
+			if err := setCardField(&def, targets[i], cell); err != nil {
+				_, column := reader.FieldPos(i)
+				rowErr = &sejm.ParseError{Field: header[i], Value: cell, Column: column, Reason: err.Error()}
+				break
+			}
+		}
+		if rowErr == nil {
+			var card interface{}
+			card, rowErr = def.Card()
+			if rowErr == nil {
+				cards = append(cards, DeckCard{line, card, def.Copies})
+				continue
+			}
+		}
+		errs = append(errs, &DeckError{name, line, rowErr})
+	}
+	return cards, errs
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// targets resolves the card field filled by each column of the header.
+func (mapping ColumnMapping) targets(header []string) ([]string, error) {
+	explicit := map[string]string{}
+	for column, field := range mapping.Columns {
+		if err := checkCardField(field); err != nil {
+			return nil, fmt.Errorf("column mapping %q: %v", column, err)
+		}
+		explicit[normalizeHeader(column)] = field
+	}
+
+	targets := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, column := range header {
+		key := normalizeHeader(column)
+		seen[key] = true
+		switch field, ok := explicit[key]; {
+		case ok:
+			targets[i] = field
+		case checkCardField(key) == nil:
+			targets[i] = key
+		case game.GroupIndex(key) >= 0:
+			targets[i] = "opinions." + game.Groups[game.GroupIndex(key)].ID
+		case game.IndicatorIndex(key) >= 0:
+			targets[i] = "effects." + game.Indicators[game.IndicatorIndex(key)].ID
+		}
+	}
+
+	for column := range mapping.Columns {
+		if !seen[normalizeHeader(column)] {
+			return nil, fmt.Errorf("column %q from the mapping is not in the header", column)
+		}
+	}
+	return targets, nil
+}
+
+func normalizeHeader(column string) string {
+	return strings.ToLower(strings.TrimSpace(column))
+}
+
+// splitCardField splits "opinions[2]" or "opinions.soc" into the list name
+// and the group or indicator name. Other fields are returned unchanged.
+func splitCardField(field string) (string, string, error) {
+	for _, list := range []struct {
+		name  string
+		names []string
+	}{{"opinions", game.GroupIDs()}, {"effects", game.IndicatorIDs()}} {
+		if name, ok := strings.CutPrefix(field, list.name+"."); ok {
+			return list.name, name, nil
+		}
+		if index, ok := strings.CutPrefix(field, list.name+"["); ok {
+			i, err := strconv.Atoi(strings.TrimSuffix(index, "]"))
+			if err != nil || !strings.HasSuffix(index, "]") || i < 0 || i >= len(list.names) {
+				return "", "", fmt.Errorf("%q is not a valid index, expected %s[0] to %s[%d]", field, list.name, list.name, len(list.names)-1)
+			}
+			return list.name, list.names[i], nil
+		}
+	}
+	if field == "" {
+		return "", "", fmt.Errorf("no card field given")
+	}
+	return field, "", nil
+}
+
+func checkCardField(field string) error {
+	field, name, err := splitCardField(field)
+	if err != nil {
+		return err
+	}
+	switch field {
+	case "type", "art", "title", "description", "symbol", "cost", "currency", "red_text", "copies":
+		return nil
+	case "opinions", "effects":
+		if name != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a card field", field)
+}
+
+func setCardField(def *CardDef, field, value string) error {
+	field, name, err := splitCardField(field)
+	if err != nil {
+		return err
+	}
+
+	switch field {
+	case "type":
+		kind, ok := parseKind(value)
+		if !ok {
+			return fmt.Errorf("is not legislation or action")
+		}
+		def.Type = kind
+	case "art":
+		def.Art = value
+	case "title":
+		def.Title = value
+	case "description":
+		def.Description = value
+	case "symbol":
+		def.Symbol = sejm.Symbol(strings.ToLower(value))
+	case "red_text":
+		def.RedText = value
+	case "copies":
+		copies, err := strconv.Atoi(value)
+		if err != nil || copies < 1 {
+			return fmt.Errorf("is not a positive integer")
+		}
+		def.Copies = copies
+	case "cost", "currency":
+		if def.Cost == nil {
+			def.Cost = &CostDef{}
+		}
+		if field == "currency" {
+			def.Cost.Currency = sejm.Currency(strings.ToLower(value))
+			return nil
+		}
+		cost, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("is not an integer")
+		}
+		def.Cost.Value = cost
+	case "opinions", "effects":
+		number, ok := sejm.ParseSignedValue(value)
+		if !ok {
+			return fmt.Errorf("is not a number or a run of + or -")
+		}
+		if field == "opinions" {
+			def.Opinions[name] = number
+		} else {
+			def.Effects[name] = number
+		}
+	default:
+		return fmt.Errorf("%q is not a card field", field)
+	}
+	return nil
+}
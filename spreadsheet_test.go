@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sejm_generator/sejm"
+)
+
+func TestReadSpreadsheetDeck(t *testing.T) {
+	sheet := "Typ\tGrafika\tTytuł\tkat\tprg\tdochod\tKoszt\tcurrency\tsymbol\n" +
+		"legislation\tustawa\tUstawa\t1\t-2\t3\t2\t\t\n" +
+		"# a comment\n" +
+		"\t\t\t\t\t\t\t\t\n" +
+		"action\takcja\tAkcja\t0\t0\t0\t1\ttrust\ttable\n"
+	mapping := ColumnMapping{Columns: map[string]string{"Typ": "type", "Grafika": "art", "Tytuł": "title", "Koszt": "cost"}}
+
+	cards, errs := ReadSpreadsheetDeck(strings.NewReader(sheet), "deck.tsv", '\t', mapping)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	want := []DeckCard{
+		{2, sejm.LegislationCard{ArtPath: "ustawa.png", Title: "Ustawa", Opinions: []sejm.Opinion{1, -2, 0, 0, 0, 0, 0, 0, 0, 0}, Effects: []int{3, 0, 0, 0, 0, 0, 0}, Cost: sejm.Cost{Value: 2, Currency: "cash"}}, 0},
+		{5, sejm.ActionCard{ArtPath: "akcja.png", Title: "Akcja", Symbol: "table", Cost: sejm.Cost{Value: 1, Currency: "trust"}}, 0},
+	}
+	if !reflect.DeepEqual(cards, want) {
+		t.Errorf("got %#v, want %#v", cards, want)
+	}
+}
+
+func TestReadSpreadsheetDeckErrors(t *testing.T) {
+	tests := []struct {
+		sheet string
+		line  int
+		err   string
+	}{
+		{"type,art,title\n\"abc", 2, `extraneous or missing " in quoted-field`},
+		{"type,art,title\nlegislation,a\"b,x\n", 2, `bare " in non-quoted-field`},
+		{"type,art,title,cost\naction,a,A,dużo\n", 2, `cost: "dużo"`},
+		{"type,art,title,kat\naction,a,A,1\n", 2, "only allowed on legislation cards"},
+		{"type,art\nlegislation,a\n", 2, "title: is required"},
+	}
+	for _, test := range tests {
+		_, errs := ReadSpreadsheetDeck(strings.NewReader(test.sheet), "deck.csv", ',', ColumnMapping{})
+		if len(errs) != 1 || errorLine(errs[0]) != test.line || !strings.Contains(errs[0].Error(), test.err) {
+			t.Errorf("ReadSpreadsheetDeck(%q): %v, want line %d: %q", test.sheet, errs, test.line, test.err)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+
+	"sejm_generator/sejm"
+)
+
+// Colours of the report charts
+const (
+	colorExtraFor     = "#1b5e20"
+	colorFor          = "#66bb6a"
+	colorAgainst      = "#ef5350"
+	colorExtraAgainst = "#b71c1c"
+	colorEffect       = "#1565c0"
+	colorCost         = "#6a1b9a"
+)
+
+// deckStats counts the cards of the decks, copies as many times as they
+// are printed.
+func deckStats(cards []DeckCard, copies int) *sejm.Stats {
+	stats := game.NewStats()
+	for _, card := range cards {
+		n := card.Copies
+		if n == 0 {
+			n = copies
+		}
+		stats.Add(card.Card, n)
+	}
+	return stats
+}
+
+// writeStatsFile writes the stats to a file with one of the writers.
+func writeStatsFile(path string, stats *sejm.Stats, write func(io.Writer, *sejm.Stats) error) error {
+	var out bytes.Buffer
+	if err := write(&out, stats); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, out.Bytes())
+}
+
+// writeStatsTable writes the stats as tables for the terminal.
+func writeStatsTable(w io.Writer, stats *sejm.Stats) error {
+	fmt.Fprintf(w, "Opinions of %d legislation cards\n", stats.Legislation)
+	fmt.Fprintf(w, "  %-18s %6s %6s %6s %6s %6s\n", "group", "++", "+", "-", "--", "net")
+	for i, group := range game.Groups {
+		fmt.Fprintf(w, "  %-18s %6d %6d %6d %6d %+6d\n", group.Name,
+			stats.Opinion(i, sejm.ExtraFor), stats.Opinion(i, sejm.For),
+			stats.Opinion(i, sejm.Against), stats.Opinion(i, sejm.ExtraAgainst), netSupport(stats, i))
+	}
+
+	fmt.Fprintf(w, "\nEffects of %d legislation cards\n", stats.Legislation)
+	fmt.Fprintf(w, "  %-18s %6s %8s %6s\n", "indicator", "total", "average", "cards")
+	for i, indicator := range game.Indicators {
+		fmt.Fprintf(w, "  %-18s %+6d %+8.2f %6d\n", indicator.Name, stats.Effects[i], stats.AverageEffect(i), stats.Changes[i])
+	}
+
+	fmt.Fprintf(w, "\nCosts of %d action cards, %d free\n", stats.Action, stats.NoCost)
+	values := stats.CostValues()
+	fmt.Fprintf(w, "  %-18s", "currency")
+	for _, value := range values {
+		fmt.Fprintf(w, " %4d", value)
+	}
+	fmt.Fprintf(w, " %6s\n", "cards")
+	for _, currency := range game.Currencies {
+		fmt.Fprintf(w, "  %-18s", currency.Name)
+		total := 0
+		for _, value := range values {
+			count := stats.Costs[currency.ID][value]
+			total += count
+			fmt.Fprintf(w, " %4d", count)
+		}
+		fmt.Fprintf(w, " %6d\n", total)
+	}
+	return nil
+}
+
+// netSupport weighs the opinions of a group, strong ones double.
+func netSupport(stats *sejm.Stats, group int) int {
+	return 2*stats.Opinion(group, sejm.ExtraFor) + stats.Opinion(group, sejm.For) -
+		stats.Opinion(group, sejm.Against) - 2*stats.Opinion(group, sejm.ExtraAgainst)
+}
+
+// writeStatsCSV writes one row per number, in the columns section, id,
+// name, measure and value, to be pivoted in a spreadsheet.
+func writeStatsCSV(w io.Writer, stats *sejm.Stats) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"section", "id", "name", "measure", "value"})
+	row := func(section, id, name, measure string, value string) {
+		out.Write([]string{section, id, name, measure, value})
+	}
+
+	row("cards", "legislation", "", "count", strconv.Itoa(stats.Legislation))
+	row("cards", "action", "", "count", strconv.Itoa(stats.Action))
+	for i, group := range game.Groups {
+		for _, opinion := range []sejm.Opinion{sejm.ExtraFor, sejm.For, sejm.Against, sejm.ExtraAgainst} {
+			row("opinions", group.ID, group.Name, opinionMeasure(opinion), strconv.Itoa(stats.Opinion(i, opinion)))
+		}
+	}
+	for i, indicator := range game.Indicators {
+		row("effects", indicator.ID, indicator.Name, "total", strconv.Itoa(stats.Effects[i]))
+		row("effects", indicator.ID, indicator.Name, "average", strconv.FormatFloat(stats.AverageEffect(i), 'f', 3, 64))
+		row("effects", indicator.ID, indicator.Name, "cards", strconv.Itoa(stats.Changes[i]))
+	}
+	row("costs", "", "", "free", strconv.Itoa(stats.NoCost))
+	for _, currency := range game.Currencies {
+		for _, value := range stats.CostValues() {
+			row("costs", string(currency.ID), currency.Name, strconv.Itoa(value), strconv.Itoa(stats.Costs[currency.ID][value]))
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func opinionMeasure(opinion sejm.Opinion) string {
+	switch opinion {
+	case sejm.ExtraFor:
+		return "extra_for"
+	case sejm.For:
+		return "for"
+	case sejm.Against:
+		return "against"
+	}
+	return "extra_against"
+}
+
+var statsPage = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
+<html lang="pl">
+<head>
+<meta charset="utf-8">
+<title>Sejm deck statistics</title>
+<style>
+  body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+  table { border-collapse: collapse; margin: 1em 0; }
+  th, td { padding: .2em .8em; text-align: right; border-bottom: 1px solid #ddd; }
+  th:first-child, td:first-child { text-align: left; }
+  .legend span { display: inline-block; width: 1em; height: 1em; vertical-align: middle; margin: 0 .3em 0 1em; }
+</style>
+</head>
+<body>
+<h1>Sejm deck statistics</h1>
+<p>{{.Stats.Legislation}} legislation cards and {{.Stats.Action}} action cards, counting copies.</p>
+
+<h2>Opinions</h2>
+<p class="legend">
+  <span style="background:{{.Colors.ExtraAgainst}}"></span>strongly against
+  <span style="background:{{.Colors.Against}}"></span>against
+  <span style="background:{{.Colors.For}}"></span>for
+  <span style="background:{{.Colors.ExtraFor}}"></span>strongly for
+</p>
+{{.Opinions}}
+<table>
+<tr><th>Group</th><th>++</th><th>+</th><th>-</th><th>--</th><th>Net</th></tr>
+{{range .Groups}}<tr><td>{{.Name}}</td><td>{{.ExtraFor}}</td><td>{{.For}}</td><td>{{.Against}}</td><td>{{.ExtraAgainst}}</td><td>{{printf "%+d" .Net}}</td></tr>
+{{end}}</table>
+
+<h2>Effects</h2>
+{{.Effects}}
+<table>
+<tr><th>Indicator</th><th>Total</th><th>Average</th><th>Cards</th></tr>
+{{range .Indicators}}<tr><td>{{.Name}}</td><td>{{printf "%+d" .Total}}</td><td>{{printf "%+.2f" .Average}}</td><td>{{.Cards}}</td></tr>
+{{end}}</table>
+
+<h2>Action card costs</h2>
+<p>{{.Stats.NoCost}} action cards cost nothing.</p>
+{{range .Costs}}<h3>{{.Name}}</h3>
+{{.Chart}}
+{{else}}<p>No action card costs anything.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// writeStatsHTML writes a report with the charts drawn as inline SVG, so
+// that the page is a single file.
+func writeStatsHTML(w io.Writer, stats *sejm.Stats) error {
+	type groupRow struct {
+		Name                                      string
+		ExtraFor, For, Against, ExtraAgainst, Net int
+	}
+	type indicatorRow struct {
+		Name         string
+		Total, Cards int
+		Average      float64
+	}
+	type costChart struct {
+		Name  string
+		Chart template.HTML
+	}
+	page := struct {
+		Stats      *sejm.Stats
+		Colors     struct{ ExtraFor, For, Against, ExtraAgainst string }
+		Groups     []groupRow
+		Indicators []indicatorRow
+		Costs      []costChart
+		Opinions   template.HTML
+		Effects    template.HTML
+	}{Stats: stats}
+	page.Colors.ExtraFor, page.Colors.For, page.Colors.Against, page.Colors.ExtraAgainst = colorExtraFor, colorFor, colorAgainst, colorExtraAgainst
+
+	var labels []string
+	var left, right [][]chartBar
+	for i, group := range game.Groups {
+		row := groupRow{group.Name, stats.Opinion(i, sejm.ExtraFor), stats.Opinion(i, sejm.For),
+			stats.Opinion(i, sejm.Against), stats.Opinion(i, sejm.ExtraAgainst), netSupport(stats, i)}
+		page.Groups = append(page.Groups, row)
+		labels = append(labels, group.Name)
+		left = append(left, []chartBar{{row.Against, colorAgainst}, {row.ExtraAgainst, colorExtraAgainst}})
+		right = append(right, []chartBar{{row.For, colorFor}, {row.ExtraFor, colorExtraFor}})
+	}
+	page.Opinions = divergingChart(labels, left, right, false)
+
+	labels, left, right = nil, nil, nil
+	for i, indicator := range game.Indicators {
+		total := stats.Effects[i]
+		page.Indicators = append(page.Indicators, indicatorRow{indicator.Name, total, stats.Changes[i], stats.AverageEffect(i)})
+		labels = append(labels, indicator.Name)
+		if total < 0 {
+			left, right = append(left, []chartBar{{-total, colorEffect}}), append(right, nil)
+		} else {
+			left, right = append(left, nil), append(right, []chartBar{{total, colorEffect}})
+		}
+	}
+	page.Effects = divergingChart(labels, left, right, true)
+
+	values := stats.CostValues()
+	for _, currency := range game.Currencies {
+		if len(stats.Costs[currency.ID]) == 0 {
+			continue
+		}
+		var labels []string
+		var counts []int
+		for _, value := range values {
+			labels = append(labels, fmt.Sprintf("%+d", value))
+			counts = append(counts, stats.Costs[currency.ID][value])
+		}
+		page.Costs = append(page.Costs, costChart{currency.Name, columnChart(labels, counts, colorCost)})
+	}
+	return statsPage.Execute(w, page)
+}
+
+// chartBar is a segment of a bar of a chart.
+type chartBar struct {
+	value int
+	color string
+}
+
+// divergingChart draws a horizontal bar per label, the left segments
+// stacked leftwards from the middle and the right ones rightwards, each
+// side labelled with its total, the left one negative when signed.
+func divergingChart(labels []string, left, right [][]chartBar, signed bool) template.HTML {
+	const (
+		labelWidth = 170
+		halfWidth  = 260
+		rowHeight  = 28
+		barHeight  = 18
+	)
+	sum := func(bars []chartBar) int {
+		total := 0
+		for _, bar := range bars {
+			total += bar.value
+		}
+		return total
+	}
+	largest := 1
+	for i := range labels {
+		largest = max(largest, sum(left[i]), sum(right[i]))
+	}
+	scale := float64(halfWidth-30) / float64(largest)
+	middle := labelWidth + halfWidth
+	width, height := labelWidth+2*halfWidth, rowHeight*len(labels)+10
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="13">`, width, height, width, height)
+	for i, label := range labels {
+		y := 5 + i*rowHeight
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" dominant-baseline="middle">%s</text>`, 0, y+rowHeight/2, html.EscapeString(label))
+		x := float64(middle)
+		for _, bar := range left[i] {
+			if bar.value == 0 {
+				continue
+			}
+			length := float64(bar.value) * scale
+			x -= length
+			fmt.Fprintf(&svg, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`, x, y+(rowHeight-barHeight)/2, length, barHeight, bar.color)
+		}
+		if total := sum(left[i]); total > 0 {
+			if signed {
+				total = -total
+			}
+			fmt.Fprintf(&svg, `<text x="%.1f" y="%d" text-anchor="end" dominant-baseline="middle">%d</text>`, x-4, y+rowHeight/2, total)
+		}
+		x = float64(middle)
+		for _, bar := range right[i] {
+			if bar.value == 0 {
+				continue
+			}
+			length := float64(bar.value) * scale
+			fmt.Fprintf(&svg, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`, x, y+(rowHeight-barHeight)/2, length, barHeight, bar.color)
+			x += length
+		}
+		if total := sum(right[i]); total > 0 {
+			fmt.Fprintf(&svg, `<text x="%.1f" y="%d" dominant-baseline="middle">%d</text>`, x+4, y+rowHeight/2, total)
+		}
+	}
+	fmt.Fprintf(&svg, `<line x1="%d" y1="0" x2="%d" y2="%d" stroke="#333"/>`, middle, middle, height)
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+// columnChart draws a vertical bar per label, labelled with its value.
+func columnChart(labels []string, values []int, color string) template.HTML {
+	const (
+		columnWidth = 48
+		barWidth    = 32
+		chartHeight = 160
+	)
+	largest := 1
+	for _, value := range values {
+		largest = max(largest, value)
+	}
+	scale := float64(chartHeight) / float64(largest)
+	width, height := columnWidth*len(labels)+10, chartHeight+50
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="13">`, width, height, width, height)
+	base := chartHeight + 20
+	for i, label := range labels {
+		x := 5 + i*columnWidth
+		length := float64(values[i]) * scale
+		fmt.Fprintf(&svg, `<rect x="%d" y="%.1f" width="%d" height="%.1f" fill="%s"/>`, x+(columnWidth-barWidth)/2, float64(base)-length, barWidth, length, color)
+		fmt.Fprintf(&svg, `<text x="%d" y="%.1f" text-anchor="middle">%d</text>`, x+columnWidth/2, float64(base)-length-5, values[i])
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle">%s</text>`, x+columnWidth/2, base+18, html.EscapeString(label))
+	}
+	fmt.Fprintf(&svg, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, base, width, base)
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
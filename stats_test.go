@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"sejm_generator/sejm"
+)
+
+// testStats counts a small deck: two copies of a bill liked by Katolicy
+// that cuts Dochód, a trust action card and a free one.
+func testStats() *sejm.Stats {
+	cards := []DeckCard{
+		{1, sejm.NewLegislationCard("a", "A", []sejm.Opinion{2, 1, 0, 0, 0, 0, 0, 0, 0, -2}, []int{-2, 0, 0, 0, 0, 0, 1}, 1), 0},
+		{2, sejm.NewActionCard("b", "B", "", sejm.NoSymbol, 3, "trust", ""), 1},
+		{3, sejm.NewActionCard("c", "C", "", sejm.NoSymbol, 0, "", ""), 1},
+	}
+	return deckStats(cards, 2)
+}
+
+func TestWriteStatsCSV(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeStatsCSV(&out, testStats()); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := strings.Join(rows[0], ","); header != "section,id,name,measure,value" {
+		t.Errorf("header %s", header)
+	}
+	values := map[string]string{}
+	for _, row := range rows[1:] {
+		values[row[0]+" "+row[1]+" "+row[3]] = row[4]
+	}
+	// Two cards, each group by opinion, each indicator by three measures,
+	// the free cards and each currency by the one cost
+	if want := 2 + 4*len(game.Groups) + 3*len(game.Indicators) + 1 + len(game.Currencies); len(rows)-1 != want {
+		t.Errorf("%d rows, want %d", len(rows)-1, want)
+	}
+	want := map[string]string{
+		"cards legislation count":    "2",
+		"cards action count":         "2",
+		"opinions kat extra_for":     "2",
+		"opinions kat for":           "0",
+		"opinions prg for":           "2",
+		"opinions cen extra_against": "2",
+		"effects dochod total":       "-4",
+		"effects dochod average":     "-2.000",
+		"effects dochod cards":       "2",
+		"effects inflacja total":     "2",
+		"effects zdrowie cards":      "0",
+		"costs  free":                "1",
+		"costs trust 3":              "1",
+		"costs cash 3":               "0",
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s = %q, want %q", key, values[key], value)
+		}
+	}
+}
+
+func TestWriteStatsHTML(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeStatsHTML(&out, testStats()); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+	for _, want := range []string{
+		"<p>2 legislation cards and 2 action cards, counting copies.</p>",
+		"<tr><td>" + game.Groups[0].Name + "</td><td>2</td><td>0</td><td>0</td><td>0</td><td>&#43;4</td></tr>", // html/template escapes the plus
+		"<tr><td>" + game.Groups[9].Name + "</td><td>0</td><td>0</td><td>0</td><td>2</td><td>-4</td></tr>",
+		"<tr><td>" + game.Indicators[0].Name + "</td><td>-4</td><td>-2.00</td><td>2</td></tr>",
+		"<p>1 action cards cost nothing.</p>",
+		"<h3>Zaufanie</h3>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %s", want)
+		}
+	}
+	// A chart of opinions, one of effects and one per currency cards cost
+	if strings.Contains(page, "<h3>Gotówka</h3>") {
+		t.Error("page has a chart of a currency no card costs")
+	}
+	if charts := strings.Count(page, "<svg "); charts != 3 {
+		t.Errorf("%d charts, want 3", charts)
+	}
+
+	out.Reset()
+	if err := writeStatsHTML(&out, game.NewStats()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "<p>No action card costs anything.</p>") {
+		t.Error("page of an empty deck doesn't say no card costs anything")
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sejm_generator/sejm"
+)
+
+// A structured deck describes cards with named fields instead of card codes:
+//
+//	cards:
+//	  - type: legislation
+//	    art: ustawa
+//	    title: Ustawa o czymś
+//	    opinions: {kat: 1, prg: 2, soc: -2}
+//	    effects: {dochod: 1, inflacja: -2}
+//	    cost: {value: 3, currency: cash}
+//	  - type: action
+//	    art: akcja
+//	    title: Akcja
+//	    description: Opis akcji
+//	    symbol: table
+//	    cost: {value: 2, currency: trust}
+//	    red_text: Czerwony tekst
+//	    copies: 3
+//
+// copies is how many of the card to print. JSON decks use the same field
+// names. deck.schema.json describes both.
+
+//go:embed deck.schema.json
+var deckSchema []byte
+
+// gameSchema returns deckSchema with the groups, indicators, currencies
+// and symbols of a custom game in place of the default ones.
+func gameSchema(game *sejm.Game) ([]byte, error) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(deckSchema, &schema); err != nil {
+		return nil, err
+	}
+	legislation := schemaObject(schema, "$defs", "legislation", "properties")
+	action := schemaObject(schema, "$defs", "action", "properties")
+
+	opinions := map[string]interface{}{}
+	for _, group := range game.Groups {
+		for _, name := range schemaNames(group.ID, group.Name, group.Aliases) {
+			opinions[name] = map[string]interface{}{"$ref": "#/$defs/opinion"}
+		}
+	}
+	effects := map[string]interface{}{}
+	for _, indicator := range game.Indicators {
+		for _, name := range schemaNames(indicator.ID, indicator.Name, indicator.Aliases) {
+			effects[name] = map[string]interface{}{"$ref": "#/$defs/effect"}
+		}
+	}
+	schemaObject(legislation, "opinions")["properties"] = opinions
+	schemaObject(legislation, "effects")["properties"] = effects
+	schemaObject(legislation, "cost", "properties")["currency"] = map[string]interface{}{"const": game.LegislationCurrency}
+
+	action["symbol"] = map[string]interface{}{"enum": append([]string{"", "none"}, game.SymbolIDs()...)}
+	schemaObject(action, "cost", "properties")["currency"] = map[string]interface{}{"enum": game.CurrencyIDs()}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaObject follows keys down nested JSON objects.
+func schemaObject(object map[string]interface{}, keys ...string) map[string]interface{} {
+	for _, key := range keys {
+		object = object[key].(map[string]interface{})
+	}
+	return object
+}
+
+// schemaNames lists the names a group or an indicator is written as in
+// decks, in lower case like the default schema.
+func schemaNames(id, name string, aliases []string) []string {
+	var names []string
+	for _, item := range append([]string{id, name}, aliases...) {
+		item = strings.ToLower(item)
+		if item != "" && !contains(names, item) {
+			names = append(names, item)
+		}
+	}
+	return names
+}
+
+type DeckFile struct {
+	Schema string    `json:"$schema,omitempty" yaml:"$schema,omitempty"`
+	Cards  []CardDef `json:"cards" yaml:"cards"`
+}
+
+type CardDef struct {
+	Type        sejm.CardKind `json:"type" yaml:"type"`
+	Art         string        `json:"art" yaml:"art"`
+	Title       string        `json:"title" yaml:"title"`
+	Description string        `json:"description,omitempty" yaml:"description,omitempty"`
+	Opinions    NamedValues   `json:"opinions,omitempty" yaml:"opinions,omitempty"`
+	Effects     NamedValues   `json:"effects,omitempty" yaml:"effects,omitempty"`
+	Cost        *CostDef      `json:"cost,omitempty" yaml:"cost,omitempty"`
+	Symbol      sejm.Symbol   `json:"symbol,omitempty" yaml:"symbol,omitempty"`
+	RedText     string        `json:"red_text,omitempty" yaml:"red_text,omitempty"`
+	Copies      int           `json:"copies,omitempty" yaml:"copies,omitempty"`
+}
+
+type CostDef struct {
+	Value    int           `json:"value" yaml:"value"`
+	Currency sejm.Currency `json:"currency,omitempty" yaml:"currency,omitempty"`
+}
+
+// NamedValues maps group or indicator names to values. It is written in
+// the order of the groups and indicators of the game rather than
+// alphabetically.
+type NamedValues map[string]int
+
+func (values NamedValues) orderedKeys() []string {
+	var keys []string
+	for _, name := range append(game.GroupIDs(), game.IndicatorIDs()...) {
+		if _, ok := values[name]; ok {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// nonZero reports whether any of the values isn't 0.
+func (values NamedValues) nonZero() bool {
+	for _, value := range values {
+		if value != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (values NamedValues) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, key := range values.orderedKeys() {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%q:%d", key, values[key])
+	}
+	b.WriteString("}")
+	return []byte(b.String()), nil
+}
+
+func (values NamedValues) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+	for _, key := range values.orderedKeys() {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprint(values[key])},
+		)
+	}
+	return node, nil
+}
+
+// structuredFormat returns "json" or "yaml" for structured deck files and
+// "" for card code decks.
+func structuredFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
+
+func LoadStructuredDeck(path string) ([]DeckCard, []error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer file.Close()
+
+	return ReadStructuredDeck(file, path)
+}
+
+// ReadStructuredDeck reads a JSON or YAML deck. JSON is read as YAML, which
+// keeps the line numbers of each card for error messages.
+func ReadStructuredDeck(r io.Reader, name string) ([]DeckCard, []error) {
+	var root yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&root); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, []error{fmt.Errorf("%s: %v", name, err)}
+	}
+
+	doc := &root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if err := checkKeys(doc, "deck", "$schema", "cards"); err != nil {
+		return nil, []error{&DeckError{name, doc.Line, err}}
+	}
+	list := mappingValue(doc, "cards")
+	if list == nil {
+		return nil, []error{&DeckError{name, doc.Line, fmt.Errorf("missing \"cards\" list")}}
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, []error{&DeckError{name, list.Line, fmt.Errorf("\"cards\" must be a list")}}
+	}
+
+	var cards []DeckCard
+	var errs []error
+	for _, node := range list.Content {
+		def, err := decodeCardDef(node)
+		var card interface{}
+		if err == nil {
+			card, err = def.Card()
+		}
+		if err != nil {
+			errs = append(errs, &DeckError{name, node.Line, err})
+			continue
+		}
+		cards = append(cards, DeckCard{node.Line, card, def.Copies})
+	}
+	return cards, errs
+}
+
+func decodeCardDef(node *yaml.Node) (CardDef, error) {
+	var def CardDef
+	err := checkKeys(node, "card", "type", "art", "title", "description", "opinions", "effects", "cost", "symbol", "red_text", "copies")
+	if err != nil {
+		return def, err
+	}
+	if cost := mappingValue(node, "cost"); cost != nil {
+		if err := checkKeys(cost, "cost", "value", "currency"); err != nil {
+			return def, err
+		}
+	}
+
+	if err := node.Decode(&def); err != nil {
+		return def, err
+	}
+	if copies := mappingValue(node, "copies"); copies != nil && def.Copies < 1 {
+		return def, &sejm.ParseError{Field: "copies", Value: copies.Value, Column: copies.Column, Reason: "must be at least 1"}
+	}
+	return def, nil
+}
+
+// checkKeys rejects mapping keys other than the allowed ones, so that a
+// misspelt field is reported instead of silently ignored.
+func checkKeys(node *yaml.Node, field string, allowed ...string) error {
+	if node.Kind != yaml.MappingNode {
+		return &sejm.ParseError{Field: field, Column: node.Column, Reason: "must be a mapping with fields " + strings.Join(allowed, ", ")}
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if !contains(allowed, key) {
+			return &sejm.ParseError{Field: field, Value: key, Column: node.Content[i].Column, Reason: "is not a known field"}
+		}
+	}
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Card converts the definition into a LegislationCard or an ActionCard.
+func (def CardDef) Card() (interface{}, error) {
+	if def.Art == "" {
+		return nil, &sejm.ParseError{Field: "art", Reason: "is required"}
+	}
+	if def.Title == "" {
+		return nil, &sejm.ParseError{Field: "title", Reason: "is required"}
+	}
+
+	cost := CostDef{}
+	if def.Cost != nil {
+		cost = *def.Cost
+	}
+
+	switch def.Type {
+	case sejm.KindLegislation:
+		for field, value := range map[string]string{"description": def.Description, "symbol": string(def.Symbol), "red_text": def.RedText} {
+			if value != "" {
+				return nil, &sejm.ParseError{Field: field, Value: value, Reason: "is only allowed on action cards"}
+			}
+		}
+		if currency, ok := game.Currency(string(cost.Currency)); cost.Currency != "" && (!ok || currency != game.LegislationCurrency) {
+			return nil, &sejm.ParseError{Field: "cost.currency", Value: string(cost.Currency), Reason: fmt.Sprintf("must be %s on legislation cards", game.LegislationCurrency)}
+		}
+
+		values, err := namedToList(def.Opinions, game.GroupIDs(), game.GroupIndex, "opinions", "group")
+		if err != nil {
+			return nil, err
+		}
+		opinions := make([]sejm.Opinion, len(values))
+		for i, value := range values {
+			opinions[i] = sejm.Opinion(value)
+		}
+		effects, err := namedToList(def.Effects, game.IndicatorIDs(), game.IndicatorIndex, "effects", "indicator")
+		if err != nil {
+			return nil, err
+		}
+
+		return game.NewLegislationCard(def.Art, def.Title, opinions, effects, cost.Value), nil
+
+	case sejm.KindAction:
+		// Zeros are allowed, spreadsheets often fill every cell
+		if def.Opinions.nonZero() || def.Effects.nonZero() {
+			return nil, &sejm.ParseError{Field: "opinions", Reason: "and effects are only allowed on legislation cards"}
+		}
+		symbol, ok := game.Symbol(string(def.Symbol))
+		if !ok {
+			return nil, &sejm.ParseError{Field: "symbol", Value: string(def.Symbol), Reason: "is not one of " + strings.Join(game.SymbolIDs(), ", ") + " or none"}
+		}
+		var currency sejm.Currency
+		switch {
+		case cost.Currency != "":
+			if currency, ok = game.Currency(string(cost.Currency)); !ok {
+				return nil, &sejm.ParseError{Field: "cost.currency", Value: string(cost.Currency), Reason: "is not one of " + strings.Join(game.CurrencyIDs(), ", ")}
+			}
+		case cost.Value != 0:
+			return nil, &sejm.ParseError{Field: "cost.currency", Reason: "is required when the cost is not 0"}
+		}
+
+		return sejm.NewActionCard(def.Art, def.Title, def.Description, symbol, cost.Value, currency, def.RedText), nil
+	}
+
+	return nil, &sejm.ParseError{Field: "type", Value: string(def.Type), Reason: "is not legislation or action"}
+}
+
+func namedToList(values NamedValues, names []string, lookup func(string) int, field, what string) ([]int, error) {
+	list := make([]int, len(names))
+	listed := make([]bool, len(names))
+	for _, name := range sortedKeys(values) {
+		idx := lookup(name)
+		if idx < 0 {
+			return nil, &sejm.ParseError{
+				Field:  field,
+				Value:  name,
+				Reason: fmt.Sprintf("is not a %s, expected one of %s", what, strings.Join(names, ", ")),
+			}
+		}
+		if listed[idx] {
+			return nil, &sejm.ParseError{Field: fmt.Sprintf("%s[%s]", field, names[idx]), Value: name, Reason: "is listed twice"}
+		}
+		listed[idx] = true
+		list[idx] = values[name]
+	}
+	return list, nil
+}
+
+func sortedKeys(values NamedValues) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// NewCardDef describes a LegislationCard or an ActionCard with named fields.
+func NewCardDef(card interface{}) CardDef {
+	switch card := card.(type) {
+	case sejm.LegislationCard:
+		def := CardDef{
+			Type:     sejm.KindLegislation,
+			Art:      strings.TrimSuffix(card.ArtPath, ".png"),
+			Title:    card.Title,
+			Opinions: NamedValues{},
+			Effects:  NamedValues{},
+		}
+		for i, opinion := range card.Opinions {
+			if opinion != sejm.Indifferent {
+				def.Opinions[game.Groups[i].ID] = int(opinion)
+			}
+		}
+		for i, effect := range card.Effects {
+			if effect != 0 {
+				def.Effects[game.Indicators[i].ID] = effect
+			}
+		}
+		if card.Cost.Value != 0 {
+			def.Cost = &CostDef{card.Cost.Value, card.Cost.Currency}
+		}
+		return def
+	case sejm.ActionCard:
+		def := CardDef{
+			Type:        sejm.KindAction,
+			Art:         strings.TrimSuffix(card.ArtPath, ".png"),
+			Title:       card.Title,
+			Description: card.Description,
+			Symbol:      card.Symbol,
+			RedText:     card.RedText,
+		}
+		if card.Cost.Value != 0 {
+			def.Cost = &CostDef{card.Cost.Value, card.Cost.Currency}
+		}
+		return def
+	}
+	return CardDef{}
+}
+
+// WriteStructuredDeck writes cards as a JSON or YAML deck.
+func WriteStructuredDeck(w io.Writer, cards []DeckCard, format string) error {
+	deck := DeckFile{Cards: make([]CardDef, 0, len(cards))}
+	for _, card := range cards {
+		def := NewCardDef(card.Card)
+		def.Copies = card.Copies
+		deck.Cards = append(deck.Cards, def)
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(deck)
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(deck); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("unknown deck format %q, expected json or yaml", format)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sejm_generator/sejm"
+)
+
+const testYAMLDeck = `cards:
+  - type: legislation
+    art: ustawa
+    title: Ustawa o czymś
+    opinions: {kat: 1, Progresywiści: 2, soc: -2}
+    effects: {dochod: 1, inflacja: -2}
+    cost: {value: 3, currency: cash}
+  - type: action
+    art: akcja
+    title: Akcja
+    description: Opis akcji
+    symbol: table
+    cost: {value: 2, currency: trust}
+    red_text: Czerwony tekst
+    copies: 3
+`
+
+const testJSONDeck = `{
+  "cards": [
+    {
+      "type": "legislation", "art": "ustawa", "title": "Ustawa o czymś",
+      "opinions": {"kat": 1, "prg": 2, "soc": -2},
+      "effects": {"dochod": 1, "inflacja": -2},
+      "cost": {"value": 3, "currency": "cash"}
+    },
+    {
+      "type": "action", "art": "akcja", "title": "Akcja", "description": "Opis akcji",
+      "symbol": "table", "cost": {"value": 2, "currency": "trust"}, "red_text": "Czerwony tekst",
+      "copies": 3
+    }
+  ]
+}
+`
+
+var testStructuredCards = []interface{}{
+	sejm.LegislationCard{
+		ArtPath:  "ustawa.png",
+		Title:    "Ustawa o czymś",
+		Opinions: []sejm.Opinion{1, 2, -2, 0, 0, 0, 0, 0, 0, 0},
+		Effects:  []int{1, 0, 0, 0, 0, 0, -2},
+		Cost:     sejm.Cost{Value: 3, Currency: "cash"},
+	},
+	sejm.ActionCard{
+		ArtPath:     "akcja.png",
+		Title:       "Akcja",
+		Description: "Opis akcji",
+		Symbol:      "table",
+		Cost:        sejm.Cost{Value: 2, Currency: "trust"},
+		RedText:     "Czerwony tekst",
+	},
+}
+
+func TestReadStructuredDeck(t *testing.T) {
+	for name, deck := range map[string]string{"yaml": testYAMLDeck, "json": testJSONDeck} {
+		cards, errs := ReadStructuredDeck(strings.NewReader(deck), "deck."+name)
+		if len(errs) > 0 {
+			t.Fatalf("%s: %v", name, errs)
+		}
+		if len(cards) != len(testStructuredCards) {
+			t.Fatalf("%s: %d cards, want %d", name, len(cards), len(testStructuredCards))
+		}
+		for i, card := range cards {
+			if !reflect.DeepEqual(card.Card, testStructuredCards[i]) {
+				t.Errorf("%s: card %d is %#v, want %#v", name, i, card.Card, testStructuredCards[i])
+			}
+		}
+		if cards[1].Copies != 3 {
+			t.Errorf("%s: %d copies, want 3", name, cards[1].Copies)
+		}
+	}
+}
+
+func TestReadStructuredDeckErrors(t *testing.T) {
+	tests := []struct {
+		deck string
+		line int
+		err  string
+	}{
+		{"cards:\n  - {type: action, art: a, title: A, color: red}\n", 2, `card: "color" is not a known field`},
+		{"cards:\n  - {type: legislation, art: a}\n", 2, "title: is required"},
+		{"cards:\n  - {type: action, art: a, title: A, copies: 0}\n", 2, "copies: \"0\" must be at least 1"},
+		{"cards:\n  - {type: action, art: a, title: A, opinions: {kat: 1}}\n", 2, "only allowed on legislation cards"},
+		{"cards:\n  - {type: legislation, art: a, title: A, opinions: {kot: 1}}\n", 2, `"kot" is not a group`},
+		{"talia: []\n", 1, `deck: "talia" is not a known field`},
+		{"cards: {}\n", 1, `"cards" must be a list`},
+	}
+	for _, test := range tests {
+		_, errs := ReadStructuredDeck(strings.NewReader(test.deck), "deck.yaml")
+		if len(errs) != 1 || errorLine(errs[0]) != test.line || !strings.Contains(errs[0].Error(), test.err) {
+			t.Errorf("ReadStructuredDeck(%q): %v, want line %d: %q", test.deck, errs, test.line, test.err)
+		}
+	}
+
+	// Zeros are no opinions, as spreadsheets write them
+	deck := "cards:\n  - {type: action, art: a, title: A, opinions: {kat: 0}, effects: {dochod: 0}}\n"
+	if _, errs := ReadStructuredDeck(strings.NewReader(deck), "deck.yaml"); len(errs) > 0 {
+		t.Errorf("action card with zero opinions: %v", errs)
+	}
+}
+
+func TestWriteStructuredDeck(t *testing.T) {
+	var deck []DeckCard
+	for i, card := range testStructuredCards {
+		deck = append(deck, DeckCard{Card: card, Copies: i})
+	}
+	deck = append(deck, DeckCard{Card: sejm.NewActionCard("wolna", "Wolna akcja", "", sejm.NoSymbol, 0, "", "")})
+	for _, format := range []string{"json", "yaml"} {
+		var b bytes.Buffer
+		if err := WriteStructuredDeck(&b, deck, format); err != nil {
+			t.Fatal(err)
+		}
+		// Only the legislation card and the action card costing something
+		// have a cost
+		if costs := strings.Count(b.String(), "cost"); costs != 2 {
+			t.Errorf("%s: %d costs written, want 2:\n%s", format, costs, b.String())
+		}
+		cards, errs := ReadStructuredDeck(&b, "deck."+format)
+		if len(errs) > 0 {
+			t.Fatalf("%s: reading back: %v", format, errs)
+		}
+		if len(cards) != len(deck) {
+			t.Fatalf("%s: read back %d cards, want %d", format, len(cards), len(deck))
+		}
+		for i, card := range cards {
+			if !reflect.DeepEqual(card.Card, deck[i].Card) || card.Copies != deck[i].Copies {
+				t.Errorf("%s: card %d read back as %#v, want %#v", format, i, card, deck[i])
+			}
+		}
+	}
+}
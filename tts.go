@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nfnt/resize"
+
+	"sejm_generator/sejm"
+)
+
+// Tabletop Simulator takes deck sheets of up to 10x7 cards. The last cell
+// of every sheet holds the image shown for cards hidden in a hand.
+const (
+	ttsMaxColumns = 10
+	ttsMaxRows    = 7
+	ttsSheetCards = ttsMaxColumns*ttsMaxRows - 1
+
+	ttsThumbnailSize = 256
+)
+
+// ttsSave is a Tabletop Simulator saved object, with the fields the game
+// needs to spawn it.
+type ttsSave struct {
+	SaveName     string
+	ObjectStates []*ttsObject
+}
+
+type ttsObject struct {
+	GUID             string
+	Name             string
+	Transform        ttsTransform
+	Nickname         string
+	Description      string
+	CardID           int   `json:",omitempty"`
+	DeckIDs          []int `json:",omitempty"`
+	CustomDeck       map[string]ttsCustomDeck
+	ContainedObjects []*ttsObject `json:",omitempty"`
+}
+
+type ttsTransform struct {
+	PosX   float64 `json:"posX"`
+	PosY   float64 `json:"posY"`
+	PosZ   float64 `json:"posZ"`
+	RotX   float64 `json:"rotX"`
+	RotY   float64 `json:"rotY"`
+	RotZ   float64 `json:"rotZ"`
+	ScaleX float64 `json:"scaleX"`
+	ScaleY float64 `json:"scaleY"`
+	ScaleZ float64 `json:"scaleZ"`
+}
+
+// ttsCustomDeck is one deck sheet. Cards on it are numbered 100 times its
+// key plus their cell.
+type ttsCustomDeck struct {
+	FaceURL      string
+	BackURL      string
+	NumWidth     int
+	NumHeight    int
+	BackIsHidden bool
+	UniqueBack   bool
+	Type         int
+}
+
+// ttsExport collects the files of a Tabletop Simulator export.
+type ttsExport struct {
+	opts    *options
+	dir     string
+	sheets  int
+	objects int
+}
+
+// ttsGrid returns the columns and rows of a sheet of count cells, as few
+// rows as fit. The game wants at least two of each.
+func ttsGrid(count int) (columns, rows int) {
+	rows = max((count+ttsMaxColumns-1)/ttsMaxColumns, 2)
+	columns = max((count+rows-1)/rows, 2)
+	return columns, rows
+}
+
+// ttsCellSize scales the cards down until a sheet of them fits in the
+// largest sheet size.
+func ttsCellSize(columns, rows, limit int) (width, height int) {
+	scale := math.Min(1, math.Min(
+		float64(limit)/float64(columns*layout.Width),
+		float64(limit)/float64(rows*layout.Height)))
+	return int(float64(layout.Width) * scale), int(float64(layout.Height) * scale)
+}
+
+// url returns where the game loads a file of the export from: the base
+// URL the files are uploaded to, or the file itself for local games.
+func (e *ttsExport) url(name string) (string, error) {
+	if e.opts.BaseURL != "" {
+		return strings.TrimSuffix(e.opts.BaseURL, "/") + "/" + name, nil
+	}
+	path, err := filepath.Abs(filepath.Join(e.dir, name))
+	if err != nil {
+		return "", err
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "file://" + path, nil
+}
+
+// writeJPEG writes an image of the export and returns its URL.
+func (e *ttsExport) writeJPEG(name string, img image.Image) (string, error) {
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 90}); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(filepath.Join(e.dir, name), out.Bytes()); err != nil {
+		return "", err
+	}
+	return e.url(name)
+}
+
+// guid returns the id of the next object of the export. Objects are
+// numbered in the order they are made, so that the ids are unique within
+// the save and exporting the same decks again gives the same file.
+func (e *ttsExport) guid() string {
+	e.objects++
+	return fmt.Sprintf("%06x", e.objects)
+}
+
+// deck lays out the cards of one type on sheets and returns the deck of
+// them, or the card itself for a type with a single card. Sheet keys
+// continue from the sheets of the previous decks.
+func (e *ttsExport) deck(kind sejm.CardKind, cards []DeckCard, back image.Image, position float64) (*ttsObject, error) {
+	name := fmt.Sprintf("%s-%s", e.opts.Name, kind)
+	deck := &ttsObject{
+		GUID:       e.guid(),
+		Name:       "DeckCustom",
+		Transform:  ttsTransform{PosX: position, PosY: 1, RotY: 180, RotZ: 180, ScaleX: 1, ScaleY: 1, ScaleZ: 1},
+		Nickname:   fmt.Sprintf("%s %s", e.opts.Name, kind),
+		CustomDeck: map[string]ttsCustomDeck{},
+	}
+
+	for start := 0; start < len(cards); start += ttsSheetCards {
+		chunk := cards[start:min(start+ttsSheetCards, len(cards))]
+		columns, rows := ttsGrid(len(chunk) + 1)
+		width, height := ttsCellSize(columns, rows, e.opts.SheetSize)
+		cell := image.Rect(0, 0, width, height)
+
+		backCell := cardBack(back, width, height)
+		sheet := image.NewRGBA(image.Rect(0, 0, columns*width, rows*height))
+		draw.Draw(sheet, sheet.Bounds(), image.White, image.Point{}, draw.Src)
+		last := columns*rows - 1
+		draw.Draw(sheet, cell.Add(image.Pt(last%columns*width, last/columns*height)), backCell, image.Point{}, draw.Src)
+
+		for i, card := range chunk {
+			img, err := renderer.Render(context.Background(), card.Card)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", cardArt(card.Card), err)
+			}
+			img = resize.Resize(uint(width), uint(height), img, resize.Lanczos3)
+			draw.Draw(sheet, cell.Add(image.Pt(i%columns*width, i/columns*height)), img, img.Bounds().Min, draw.Over)
+			e.opts.printf(verbose, "Placed %s\n", cardArt(card.Card))
+		}
+
+		e.sheets++
+		sheetName := fmt.Sprintf("%s-%d", name, start/ttsSheetCards+1)
+		faceURL, err := e.writeJPEG(sheetName+".jpg", sheet)
+		if err != nil {
+			return nil, err
+		}
+		backURL, err := e.writeJPEG(sheetName+"-back.jpg", backCell)
+		if err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprint(e.sheets)
+		custom := ttsCustomDeck{FaceURL: faceURL, BackURL: backURL, NumWidth: columns, NumHeight: rows}
+		deck.CustomDeck[key] = custom
+		for i, card := range chunk {
+			id := e.sheets*100 + i
+			copies := card.Copies
+			if copies == 0 {
+				copies = e.opts.Copies
+			}
+			for n := 0; n < copies; n++ {
+				deck.DeckIDs = append(deck.DeckIDs, id)
+				deck.ContainedObjects = append(deck.ContainedObjects, &ttsObject{
+					GUID:        e.guid(),
+					Name:        "Card",
+					Transform:   ttsTransform{RotY: 180, RotZ: 180, ScaleX: 1, ScaleY: 1, ScaleZ: 1},
+					Nickname:    cardTitle(card.Card),
+					Description: game.Describe(card.Card),
+					CardID:      id,
+					CustomDeck:  map[string]ttsCustomDeck{key: custom},
+				})
+			}
+		}
+	}
+
+	if len(deck.ContainedObjects) == 1 {
+		card := deck.ContainedObjects[0]
+		card.Transform = deck.Transform
+		return card, nil
+	}
+	return deck, nil
+}
+
+// cardTitle returns the title of a LegislationCard or an ActionCard.
+func cardTitle(card interface{}) string {
+	switch card := card.(type) {
+	case sejm.LegislationCard:
+		return card.Title
+	case sejm.ActionCard:
+		return card.Title
+	}
+	return ""
+}
+
+// exportTTS draws the cards of the decks onto Tabletop Simulator deck
+// sheets in dir, next to the saved object that spawns one deck per card
+// type.
+func exportTTS(opts *options, paths []string, dir string) int {
+	backs, err := opts.cardBacks()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+
+	cards, failed := loadDecks(paths)
+	if failed {
+		fmt.Println("tts: not exporting decks with missing cards")
+		return exitFailures
+	}
+	byKind := map[sejm.CardKind][]DeckCard{}
+	for _, card := range cards {
+		kind := cardKind(card.Card)
+		byKind[kind] = append(byKind[kind], card)
+	}
+
+	export := &ttsExport{opts: opts, dir: dir}
+	save := ttsSave{SaveName: opts.Name}
+	for _, kind := range []sejm.CardKind{sejm.KindLegislation, sejm.KindAction} {
+		if len(byKind[kind]) == 0 {
+			continue
+		}
+		object, err := export.deck(kind, byKind[kind], backs[kind], 3*float64(len(save.ObjectStates)))
+		if err != nil {
+			fmt.Printf("Failed %v\n", err)
+			fmt.Println("tts: not exporting decks with missing cards")
+			return exitFailures
+		}
+		save.ObjectStates = append(save.ObjectStates, object)
+	}
+	if len(save.ObjectStates) == 0 {
+		fmt.Println("tts: the decks have no cards")
+		return exitFailures
+	}
+
+	data, err := json.MarshalIndent(save, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	output := filepath.Join(dir, opts.Name+".json")
+	if err := writeFileAtomic(output, append(data, '\n')); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+
+	// The game lists saved objects with the PNG of the same name
+	thumbnail, err := renderer.Render(context.Background(), cards[0].Card)
+	if err == nil {
+		thumbnail = resize.Thumbnail(ttsThumbnailSize, ttsThumbnailSize, thumbnail, resize.Lanczos3)
+		var out bytes.Buffer
+		if err = png.Encode(&out, thumbnail); err == nil {
+			err = writeFileAtomic(filepath.Join(dir, opts.Name+".png"), out.Bytes())
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+
+	exported := 0
+	for _, object := range save.ObjectStates {
+		exported += max(len(object.DeckIDs), 1)
+	}
+	fmt.Printf("Exported %d cards on %d sheets to %s\n", exported, export.sheets, output)
+	if opts.BaseURL != "" {
+		fmt.Printf("Upload the sheet images to %s before loading it\n", opts.BaseURL)
+	}
+	return exitOK
+}
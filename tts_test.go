@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sejm_generator/sejm"
+)
+
+func TestTTSGrid(t *testing.T) {
+	tests := []struct {
+		count         int
+		columns, rows int
+	}{
+		{1, 2, 2},
+		{2, 2, 2},
+		{4, 2, 2},
+		{5, 3, 2},
+		{11, 6, 2},
+		{21, 7, 3},
+		{70, 10, 7},
+	}
+	for _, test := range tests {
+		if columns, rows := ttsGrid(test.count); columns != test.columns || rows != test.rows {
+			t.Errorf("ttsGrid(%d) = %dx%d, want %dx%d", test.count, columns, rows, test.columns, test.rows)
+		}
+	}
+}
+
+func TestTTSCellSize(t *testing.T) {
+	if width, height := ttsCellSize(2, 2, 1<<20); width != layout.Width || height != layout.Height {
+		t.Errorf("cells of a sheet with room for them are %dx%d, want the card size %dx%d", width, height, layout.Width, layout.Height)
+	}
+	for _, grid := range [][2]int{{10, 7}, {2, 2}, {7, 3}} {
+		width, height := ttsCellSize(grid[0], grid[1], 4096)
+		if grid[0]*width > 4096 || grid[1]*height > 4096 {
+			t.Errorf("%dx%d cells of %dx%d don't fit on a sheet of 4096", grid[0], grid[1], width, height)
+		}
+		if ratio, want := float64(width)/float64(height), float64(layout.Width)/float64(layout.Height); ratio < want*0.99 || ratio > want*1.01 {
+			t.Errorf("%dx%d cells of %dx%d lost the aspect ratio of the card", grid[0], grid[1], width, height)
+		}
+	}
+}
+
+// ttsSheetColor returns the colour of a cell of a sheet written by an
+// export, in the middle of where cards have their art.
+func ttsSheetColor(t *testing.T, path string, columns, rows, cell int) color.RGBA {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	sheet, err := jpeg.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	width, height := sheet.Bounds().Dx()/columns, sheet.Bounds().Dy()/rows
+	r, g, b, _ := sheet.At(cell%columns*width+width/2, cell/columns*height+height/4).RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+}
+
+// near tells whether two colours are alike, after JPEG compression.
+func near(a, b color.RGBA) bool {
+	diff := func(x, y uint8) bool { return int(x)-int(y) < 16 && int(y)-int(x) < 16 }
+	return diff(a.R, b.R) && diff(a.G, b.G) && diff(a.B, b.B)
+}
+
+func TestTTSExportDeck(t *testing.T) {
+	dir := chdirTemp(t)
+	writeTestArt(t, "a.png")
+	useSmallLayout(t)
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	back := image.NewRGBA(image.Rect(0, 0, 60, 84))
+	draw.Draw(back, back.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+
+	// A card more than a sheet holds, the first printed twice
+	var cards []DeckCard
+	for i := 0; i < ttsSheetCards+1; i++ {
+		cards = append(cards, DeckCard{Line: i + 1, Card: sejm.NewActionCard("a", fmt.Sprintf("Akcja %d", i), "", sejm.NoSymbol, 0, "", "")})
+	}
+	cards[0].Copies = 2
+
+	export := &ttsExport{opts: &options{Name: "Sejm", SheetSize: 4096, Copies: 1}, dir: dir}
+	deck, err := export.deck(sejm.KindAction, cards, back, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deck.Name != "DeckCustom" || deck.Transform.PosX != 3 {
+		t.Errorf("deck is a %s at %v", deck.Name, deck.Transform.PosX)
+	}
+	if len(deck.CustomDeck) != 2 || deck.CustomDeck["1"].NumWidth != 10 || deck.CustomDeck["1"].NumHeight != 7 ||
+		deck.CustomDeck["2"].NumWidth != 2 || deck.CustomDeck["2"].NumHeight != 2 {
+		t.Errorf("sheets %+v, want one of 10x7 and one of 2x2", deck.CustomDeck)
+	}
+
+	wantIDs := []int{100, 100}
+	for i := 1; i < ttsSheetCards; i++ {
+		wantIDs = append(wantIDs, 100+i)
+	}
+	wantIDs = append(wantIDs, 200)
+	if !reflect.DeepEqual(deck.DeckIDs, wantIDs) {
+		t.Errorf("DeckIDs %v, want %v", deck.DeckIDs, wantIDs)
+	}
+	guids := map[string]bool{deck.GUID: true}
+	for i, card := range deck.ContainedObjects {
+		if card.CardID != wantIDs[i] || len(card.CustomDeck) != 1 {
+			t.Errorf("card %d: CardID %d on sheets %v, want %d on one sheet", i, card.CardID, card.CustomDeck, wantIDs[i])
+		}
+		if guids[card.GUID] {
+			t.Errorf("card %d: GUID %s is used twice", i, card.GUID)
+		}
+		guids[card.GUID] = true
+	}
+	if last := deck.ContainedObjects[len(deck.ContainedObjects)-1]; last.Nickname != "Akcja 69" || last.CustomDeck["2"] != deck.CustomDeck["2"] {
+		t.Errorf("last card is %q on %v, want Akcja 69 on the second sheet", last.Nickname, last.CustomDeck)
+	}
+
+	// The last cell of every sheet holds the back, the cells after the
+	// cards are left white
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	sheets := []struct {
+		name          string
+		columns, rows int
+		cell          int
+		want          color.RGBA
+	}{
+		{"Sejm-action-1.jpg", 10, 7, 69, red},
+		{"Sejm-action-2.jpg", 2, 2, 3, red},
+		{"Sejm-action-2.jpg", 2, 2, 1, white},
+		{"Sejm-action-2-back.jpg", 1, 1, 0, red},
+	}
+	for _, sheet := range sheets {
+		if got := ttsSheetColor(t, filepath.Join(dir, sheet.name), sheet.columns, sheet.rows, sheet.cell); !near(got, sheet.want) {
+			t.Errorf("%s: cell %d is %v, want %v", sheet.name, sheet.cell, got, sheet.want)
+		}
+	}
+	if got := ttsSheetColor(t, filepath.Join(dir, "Sejm-action-1.jpg"), 10, 7, 68); near(got, red) || near(got, white) {
+		t.Errorf("Sejm-action-1.jpg: cell 68 is %v, want a card", got)
+	}
+
+	// A single card is spawned on its own, on the next sheet
+	single, err := export.deck(sejm.KindLegislation, cards[1:2], back, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.Name != "Card" || single.CardID != 300 || single.Transform.PosX != 6 || len(single.DeckIDs) != 0 {
+		t.Errorf("single card exported as %+v, want a Card with CardID 300 at 6", single)
+	}
+	if guids[single.GUID] {
+		t.Errorf("GUID %s of the single card is used in the other deck", single.GUID)
+	}
+}
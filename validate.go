@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"sejm_generator/sejm"
+)
+
+// CardProblem is a design rule broken by a card of a deck, or a card that
+// doesn't parse. Line is 0 for problems with the deck file as a whole.
+type CardProblem struct {
+	File string `json:"file"`
+	Line int    `json:"line,omitempty"`
+	Art  string `json:"art,omitempty"`
+	sejm.Problem
+}
+
+func (p CardProblem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %v", p.File, p.Problem)
+	}
+	return fmt.Sprintf("%s:%d: %v", p.File, p.Line, p.Problem)
+}
+
+// ValidationReport is what validate finds in a set of decks, as written
+// with -json.
+type ValidationReport struct {
+	Cards    int           `json:"cards"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Problems []CardProblem `json:"problems"`
+}
+
+func (report *ValidationReport) add(problem CardProblem) {
+	switch problem.Severity {
+	case sejm.SeverityError:
+		report.Errors++
+	case sejm.SeverityWarning:
+		report.Warnings++
+	}
+	report.Problems = append(report.Problems, problem)
+}
+
+// validateDeck checks every card of a deck against the rules, nil for the
+// default ones, without drawing anything. Cards that don't parse are
+// errors of the card rule. It returns the lines of the cards that passed.
+func validateDeck(path string, rules *sejm.Rules, report *ValidationReport) []int {
+	start := len(report.Problems)
+	cards, errs := LoadDeck(path)
+	for _, err := range errs {
+		problem := CardProblem{File: path, Problem: sejm.Problem{Rule: sejm.RuleCard, Severity: sejm.SeverityError, Message: err.Error()}}
+		var deckErr *DeckError
+		if errors.As(err, &deckErr) {
+			problem.Line, problem.Message = deckErr.Line, deckErr.Err.Error()
+			report.Cards++
+		}
+		report.add(problem)
+	}
+
+	// How card codes are written is only checked in card code decks
+	codeProblems := map[int][]sejm.Problem{}
+	if structuredFormat(path) == "" && spreadsheetSeparator(path) == 0 {
+		entries, _ := ReadDeckFile(path)
+		for _, entry := range entries {
+			codeProblems[entry.Line] = renderer.ValidateCode(entry.Kind, entry.Code, rules)
+		}
+	}
+
+	var passed []int
+	for _, card := range cards {
+		report.Cards++
+		problems := append(codeProblems[card.Line], renderer.Validate(card.Card, rules)...)
+		for _, problem := range problems {
+			report.add(CardProblem{path, card.Line, cardArt(card.Card), problem})
+		}
+		if len(problems) == 0 {
+			passed = append(passed, card.Line)
+		}
+	}
+	// Cards that don't parse come first, put them in their place
+	problems := report.Problems[start:]
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return passed
+}
+
+// validateDecks checks decks, prints what it finds and turns it into an
+// exit code. Warnings fail only when strict.
+func validateDecks(opts *options, paths []string, rules *sejm.Rules) int {
+	var report ValidationReport
+	report.Problems = []CardProblem{}
+	missing := false
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			missing = true
+			continue
+		}
+
+		opts.printf(verbose, "Validating %s\n", path)
+		printed := len(report.Problems)
+		passed := validateDeck(path, rules, &report)
+		if opts.JSON {
+			continue
+		}
+		for _, problem := range report.Problems[printed:] {
+			fmt.Println(problem)
+		}
+		for _, line := range passed {
+			opts.printf(verbose, "OK %s:%d\n", path, line)
+		}
+	}
+
+	if opts.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+	} else {
+		opts.printf(normal, "----------------------------------------------------\n")
+		fmt.Printf("Checked %d cards, %d errors, %d warnings\n", report.Cards, report.Errors, report.Warnings)
+	}
+
+	switch {
+	case missing:
+		return exitIO
+	case report.Errors > 0, opts.Strict && report.Warnings > 0:
+		return exitFailures
+	}
+	return exitOK
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nfnt/resize"
+
+	"sejm_generator/sejm"
+)
+
+// vassalVersion is the VASSAL release the module is written for.
+const vassalVersion = "3.6.7"
+
+// vassalElement is an element of the buildFile. The buildFile names its
+// elements after VASSAL's classes, so they are built as a tree rather than
+// declared.
+type vassalElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []*vassalElement
+}
+
+// el returns an element with the attributes given as name, value pairs.
+func el(class string, attrs ...string) *vassalElement {
+	e := &vassalElement{XMLName: xml.Name{Local: class}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		e.Attrs = append(e.Attrs, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	return e
+}
+
+func (e *vassalElement) add(children ...*vassalElement) *vassalElement {
+	e.Children = append(e.Children, children...)
+	return e
+}
+
+// vassalSequence joins the fields of a piece definition the way VASSAL's
+// SequenceEncoder does, escaping the separator within fields. Fields ending
+// in a backslash, which would escape the separator after them, and fields
+// in single quotes, which the decoder unquotes, are quoted.
+func vassalSequence(separator string, fields ...string) string {
+	escaped := make([]string, len(fields))
+	for i, field := range fields {
+		escaped[i] = strings.ReplaceAll(field, separator, `\`+separator)
+		if strings.HasSuffix(field, `\`) || len(field) > 0 && strings.HasPrefix(field, "'") && strings.HasSuffix(field, "'") {
+			escaped[i] = "'" + escaped[i] + "'"
+		}
+	}
+	return strings.Join(escaped, separator)
+}
+
+// vassalPiece returns the definition of a piece showing an image, wrapped
+// in its traits, as PieceSlots and CardSlots hold it. Traits are given
+// outermost first.
+func vassalPiece(gpid int, imageName, name string, traits ...string) string {
+	types := append(traits[:len(traits):len(traits)], vassalSequence(";", "piece", "", "", imageName, name))
+	states := make([]string, len(types))
+	for i := range traits {
+		states[i] = "null"
+	}
+	states[len(traits)] = vassalSequence(";", "null", "0", "0", strconv.Itoa(gpid))
+	return vassalSequence("/", "+", "null", vassalSequence("\t", types...), vassalSequence("\t", states...))
+}
+
+// vassalModule collects the files of a VASSAL module.
+type vassalModule struct {
+	opts   *options
+	zip    *zip.Writer
+	images map[string]bool
+	gpid   int
+}
+
+// nextGPID numbers the pieces of the module.
+func (m *vassalModule) nextGPID() int {
+	m.gpid++
+	return m.gpid
+}
+
+// image adds a file to the images of the module under a name no other
+// image has and returns the name.
+func (m *vassalModule) image(name string, data []byte) (string, error) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; m.images[name]; n++ {
+		name = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	m.images[name] = true
+
+	w, err := m.zip.Create("images/" + name)
+	if err != nil {
+		return "", err
+	}
+	_, err = w.Write(data)
+	return name, err
+}
+
+func (m *vassalModule) png(name string, img image.Image) (string, error) {
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return "", err
+	}
+	return m.image(name, out.Bytes())
+}
+
+// decks returns a draw pile and a discard pile for every card type with
+// cards, side by side in a row of the table per type.
+func (m *vassalModule) decks(cards []DeckCard, backs map[sejm.CardKind]image.Image, width, height int) ([]*vassalElement, int, error) {
+	byKind := map[sejm.CardKind][]DeckCard{}
+	for _, card := range cards {
+		kind := cardKind(card.Card)
+		byKind[kind] = append(byKind[kind], card)
+	}
+
+	var piles []*vassalElement
+	placed := 0
+	for _, kind := range []sejm.CardKind{sejm.KindLegislation, sejm.KindAction} {
+		if len(byKind[kind]) == 0 {
+			continue
+		}
+		back, err := m.png(fmt.Sprintf("%s-back.png", kind), cardBack(backs[kind], width, height))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		name := strings.ToUpper(string(kind[:1])) + string(kind[1:])
+		discard := name + " discard"
+		y := height + len(piles)/2*height*3/2 // piles are placed by their centre
+		deck := el("VASSAL.build.module.map.DrawPile",
+			"name", name, "owningBoard", "Table",
+			"x", strconv.Itoa(width), "y", strconv.Itoa(y),
+			"width", strconv.Itoa(width), "height", strconv.Itoa(height),
+			"faceDown", "Always", "drawFaceUp", "false", "shuffle", "Always", "reversible", "false",
+			"draw", "true", "color", "0,0,0", "allowMultiple", "false", "allowSelect", "false",
+			"reshufflable", "false")
+		for _, card := range byKind[kind] {
+			img, err := renderer.Render(context.Background(), card.Card)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%s: %v", cardArt(card.Card), err)
+			}
+			face, err := m.png(outputName(filepath.Base(cardArt(card.Card))), resize.Resize(uint(width), uint(height), img, resize.Lanczos3))
+			if err != nil {
+				return nil, 0, err
+			}
+			copies := card.Copies
+			if copies == 0 {
+				copies = m.opts.Copies
+			}
+			for n := 0; n < copies; n++ {
+				gpid := m.nextGPID()
+				// Cards in hands show their backs to the other players
+				mask := vassalSequence(";", "obs", "70,130", back, "Flip", "G", "Back", "player:")
+				slot := el("VASSAL.build.widget.CardSlot",
+					"entryName", cardTitle(card.Card), "gpid", strconv.Itoa(gpid),
+					"width", strconv.Itoa(width), "height", strconv.Itoa(height))
+				slot.Text = vassalPiece(gpid, face, cardTitle(card.Card), mask)
+				deck.add(slot)
+				placed++
+			}
+			m.opts.printf(verbose, "Added %s\n", cardArt(card.Card))
+		}
+
+		piles = append(piles, deck, el("VASSAL.build.module.map.DrawPile",
+			"name", discard, "owningBoard", "Table",
+			"x", strconv.Itoa(width*5/2), "y", strconv.Itoa(y),
+			"width", strconv.Itoa(width), "height", strconv.Itoa(height),
+			"faceDown", "Never", "drawFaceUp", "true", "shuffle", "Never", "reversible", "false",
+			"draw", "true", "color", "0,0,0", "allowMultiple", "false", "allowSelect", "true",
+			"reshufflable", "true", "reshuffleCommand", "Shuffle into "+strings.ToLower(name),
+			"reshuffleTarget", name))
+	}
+	return piles, placed, nil
+}
+
+// counters returns the palette of indicator counters, a plus and a minus
+// for every indicator of the game, size wide.
+func (m *vassalModule) counters(size int) (*vassalElement, error) {
+	list := el("VASSAL.build.widget.ListWidget", "entryName", "Indicators", "width", "0", "height", "0", "divider", "0", "scale", "1.0")
+	for _, indicator := range game.Indicators {
+		for _, counter := range []struct{ asset, name string }{
+			{indicator.Plus, indicator.Name + " +"},
+			{indicator.Minus, indicator.Name + " -"},
+		} {
+			file, err := assetSource.Open(counter.asset)
+			if err != nil {
+				return nil, err
+			}
+			img, _, err := image.Decode(file)
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("decoding %s: %v", counter.asset, err)
+			}
+			img = resize.Resize(uint(size), 0, img, resize.Lanczos3)
+			name, err := m.png(path.Base(counter.asset), img)
+			if err != nil {
+				return nil, err
+			}
+			gpid := m.nextGPID()
+			slot := el("VASSAL.build.widget.PieceSlot",
+				"entryName", counter.name, "gpid", strconv.Itoa(gpid),
+				"width", strconv.Itoa(img.Bounds().Dx()), "height", strconv.Itoa(img.Bounds().Dy()))
+			slot.Text = vassalPiece(gpid, name, counter.name)
+			list.add(slot)
+		}
+	}
+	return el("VASSAL.build.module.PieceWindow",
+		"name", "Counters", "buttonText", "Counters", "tooltip", "Indicator counters",
+		"hidden", "false", "scale", "1.0", "icon", "").add(list), nil
+}
+
+// mapComponents are the parts of a map that let pieces be moved, stacked
+// and inspected on it.
+func mapComponents(board *vassalElement) []*vassalElement {
+	return []*vassalElement{
+		el("VASSAL.build.module.map.BoardPicker", "addColumnText", "Add column", "addRowText", "Add row",
+			"boardPrompt", "Select board", "slotHeight", "125", "slotScale", "0.2", "slotWidth", "350", "title", "Choose Boards").add(board),
+		el("VASSAL.build.module.map.StackMetrics", "bottom", "40,130", "disabled", "false", "down", "39,130",
+			"exSepX", "6", "exSepY", "18", "top", "38,130", "unexSepX", "2", "unexSepY", "4", "up", "37,130"),
+		el("VASSAL.build.module.map.ForwardToKeyBuffer"),
+		el("VASSAL.build.module.map.Scroller"),
+		el("VASSAL.build.module.map.ForwardToChatter"),
+		el("VASSAL.build.module.map.MenuDisplayer"),
+		el("VASSAL.build.module.map.MapCenterer"),
+		el("VASSAL.build.module.map.StackExpander"),
+		el("VASSAL.build.module.map.PieceMover"),
+		el("VASSAL.build.module.map.KeyBufferer"),
+		el("VASSAL.build.module.map.ImageSaver"),
+		el("VASSAL.build.module.map.CounterDetailViewer", "delay", "700", "display", "from top-most layer only",
+			"graphicsZoom", "1.0", "showgraph", "true", "showtext", "false", "version", "3"),
+		el("VASSAL.build.module.map.Zoomer", "zoomLevels", "0.25,0.5,0.75,1.0,1.5", "zoomStart", "4"),
+	}
+}
+
+// buildModule writes the module of the cards to out.
+func buildModule(opts *options, cards []DeckCard, out *os.File) (int, error) {
+	backs, err := opts.cardBacks()
+	if err != nil {
+		return 0, err
+	}
+	width := opts.CardWidth
+	height := width * layout.Height / layout.Width
+
+	m := &vassalModule{opts: opts, zip: zip.NewWriter(out), images: map[string]bool{}}
+	piles, placed, err := m.decks(cards, backs, width, height)
+	if err != nil {
+		return 0, err
+	}
+	counters, err := m.counters(width / 3)
+	if err != nil {
+		return 0, err
+	}
+
+	roster := el("VASSAL.build.module.PlayerRoster", "buttonText", "Retire", "buttonToolTip", "Switch sides or become an observer")
+	var hands []*vassalElement
+	for player := 1; player <= opts.Players; player++ {
+		side := fmt.Sprintf("Player %d", player)
+		entry := el("entry")
+		entry.Text = side
+		roster.add(entry)
+
+		board := el("VASSAL.build.module.map.boardPicker.Board", "name", side+" hand", "image", "", "reversible", "false",
+			"width", strconv.Itoa(width*8), "height", strconv.Itoa(height*3/2), "color", "230,230,230")
+		hands = append(hands, el("VASSAL.build.module.PlayerHand",
+			"mapName", side+" hand", "side", side, "visible", "false",
+			"buttonName", side+" hand", "icon", "", "launch", "true", "allowMultiple", "false",
+			"backgroundcolor", "230,230,230", "markMoved", "Never", "markUnmovedIcon", "", "markUnmovedText", "",
+			"moveWithinFormat", "", "moveToFormat", "", "createFormat", "", "edgeWidth", "0", "edgeHeight", "0").
+			add(mapComponents(board)...))
+	}
+
+	table := el("VASSAL.build.module.map.boardPicker.Board", "name", "Table", "image", "", "reversible", "false",
+		"width", strconv.Itoa(width*7/2), "height", strconv.Itoa(len(piles)/2*height*3/2+height/2), "color", "40,110,60")
+	module := el("VASSAL.build.GameModule",
+		"name", opts.Name, "version", Version, "VassalVersion", vassalVersion,
+		"description", opts.Name+" cards", "nextPieceSlotId", strconv.Itoa(m.gpid+1),
+		"ModuleOther1", "", "ModuleOther2", "").add(
+		el("VASSAL.build.module.BasicCommandEncoder"),
+		el("VASSAL.build.module.Documentation"),
+		roster,
+		el("VASSAL.build.module.GlobalOptions", "autoReport", "Always", "nonOwnerUnmaskable", "Never", "playerIdFormat", "$PlayerName$"),
+		el("VASSAL.build.module.Chatter"),
+		el("VASSAL.build.module.KeyNamer"),
+		el("VASSAL.build.module.PrototypesContainer"),
+		counters,
+		el("VASSAL.build.module.Map",
+			"mapName", "Table", "allowMultiple", "false", "backgroundcolor", "255,255,255",
+			"buttonName", "", "changeFormat", "$message$", "color", "0,0,0", "createFormat", "$pieceName$ created in $location$",
+			"edgeHeight", "0", "edgeWidth", "0", "hideKey", "", "hotkey", "", "icon", "", "launch", "false",
+			"markMoved", "Never", "markUnmovedHotkey", "", "markUnmovedIcon", "", "markUnmovedText", "",
+			"moveKey", "", "moveToFormat", "$pieceName$ moves $previousLocation$ -> $location$",
+			"moveWithinFormat", "$pieceName$ moves $previousLocation$ -> $location$",
+			"showKey", "", "thickness", "3", "useLaunchButton", "false").
+			add(mapComponents(table)...).add(piles...),
+	).add(hands...)
+
+	buildFile, err := m.zip.Create("buildFile.xml")
+	if err == nil {
+		_, err = buildFile.Write([]byte(xml.Header))
+	}
+	if err == nil {
+		encoder := xml.NewEncoder(buildFile)
+		encoder.Indent("", "  ")
+		err = encoder.Encode(module)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	moduleData, err := m.zip.Create("moduledata")
+	if err == nil {
+		_, err = fmt.Fprintf(moduleData, "%s<data version=\"1\">\n  <version>%s</version>\n  <VassalVersion>%s</VassalVersion>\n  <dateSaved>%d</dateSaved>\n  <description>%s cards</description>\n  <name>%s</name>\n</data>\n",
+			xml.Header, xmlEscape(Version), vassalVersion, time.Now().UnixMilli(), xmlEscape(opts.Name), xmlEscape(opts.Name))
+	}
+	if err != nil {
+		return 0, err
+	}
+	return placed, m.zip.Close()
+}
+
+func xmlEscape(s string) string {
+	var out strings.Builder
+	xml.EscapeText(&out, []byte(s))
+	return out.String()
+}
+
+// exportVassal builds a VASSAL module of the decks, written to output
+// once every card is drawn.
+func exportVassal(opts *options, paths []string, output string) int {
+	cards, failed := loadDecks(paths)
+	if failed {
+		fmt.Println("vassal: not exporting decks with missing cards")
+		return exitFailures
+	}
+	if len(cards) == 0 {
+		fmt.Println("vassal: the decks have no cards")
+		return exitFailures
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+	defer os.Remove(temp.Name())
+
+	placed, err := buildModule(opts, cards, temp)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), output)
+	}
+	if err != nil {
+		fmt.Printf("Failed %v\n", err)
+		return exitFailures
+	}
+	fmt.Printf("Exported %d cards for %d players to %s\n", placed, opts.Players, output)
+	return exitOK
+}
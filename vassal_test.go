@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"sejm_generator/sejm"
+)
+
+func TestVassalSequence(t *testing.T) {
+	tests := []struct {
+		separator string
+		fields    []string
+		want      string
+	}{
+		{";", []string{"piece", "", "", "a.png", "Ustawa"}, "piece;;;a.png;Ustawa"},
+		{";", []string{"a;b", "c"}, `a\;b;c`},
+		{";", []string{";;"}, `\;\;`},
+		{"/", []string{"a;b/c"}, `a;b\/c`},
+		{";", []string{`a\`, "b"}, `'a\';b`},
+		{";", []string{"'cytat'", "'", "it's"}, `''cytat'';''';it's`},
+		{"\t", []string{"a\tb", ""}, "a\\\tb\t"},
+		{";", nil, ""},
+	}
+	for _, test := range tests {
+		if got := vassalSequence(test.separator, test.fields...); got != test.want {
+			t.Errorf("vassalSequence(%q, %q) = %q, want %q", test.separator, test.fields, got, test.want)
+		}
+	}
+}
+
+func TestVassalPiece(t *testing.T) {
+	tests := []struct {
+		name   string
+		traits []string
+		want   string
+	}{
+		{"Dochód +", nil, "+/null/piece;;;a.png;Dochód +/null;0;0;7"},
+		{"Ustawa; druga/trzecia", []string{"obs;70,130;back.png"},
+			"+/null/obs;70,130;back.png\tpiece;;;a.png;Ustawa\\; druga\\/trzecia/null\tnull;0;0;7"},
+	}
+	for _, test := range tests {
+		if got := vassalPiece(7, "a.png", test.name, test.traits...); got != test.want {
+			t.Errorf("vassalPiece(%q, %q) = %q, want %q", test.name, test.traits, got, test.want)
+		}
+	}
+}
+
+// vassalBuildFile counts the elements of a buildFile by name, and the
+// cards of each pile.
+func vassalBuildFile(t *testing.T, r io.Reader) (elements, piles map[string]int, module map[string]string) {
+	elements, piles, module = map[string]int{}, map[string]int{}, map[string]string{}
+	decoder := xml.NewDecoder(r)
+	pile := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return elements, piles, module
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		elements[start.Name.Local]++
+		attrs := map[string]string{}
+		for _, attr := range start.Attr {
+			attrs[attr.Name.Local] = attr.Value
+		}
+		switch start.Name.Local {
+		case "VASSAL.build.GameModule":
+			module = attrs
+		case "VASSAL.build.module.map.DrawPile":
+			pile = attrs["name"]
+			piles[pile] += 0
+		case "VASSAL.build.widget.CardSlot":
+			piles[pile]++
+		}
+	}
+}
+
+func TestBuildModule(t *testing.T) {
+	chdirTemp(t)
+	useSmallLayout(t)
+	writeTestArt(t, "a.png")
+	writeTestArt(t, "b.png")
+	cards := []DeckCard{
+		{1, sejm.NewLegislationCard("a", "Ustawa", make([]sejm.Opinion, 10), make([]int, 7), 1), 3},
+		{2, sejm.NewLegislationCard("b", "Druga ustawa", make([]sejm.Opinion, 10), make([]int, 7), 1), 0},
+		{3, sejm.NewActionCard("a", "Akcja", "Opis", sejm.NoSymbol, 0, "", ""), 0},
+	}
+
+	file, err := os.Create("Sejm.vmod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	opts := &options{Name: "Sejm & co", Players: 3, CardWidth: 60, Copies: 2}
+	placed, err := buildModule(opts, cards, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placed != 3+2+2 {
+		t.Errorf("placed %d cards, want 7", placed)
+	}
+
+	module, err := zip.OpenReader("Sejm.vmod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	var names []string
+	for _, file := range module.File {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	want := []string{"buildFile.xml", "images/a-2.png", "images/a.png", "images/action-back.png", "images/b.png", "images/legislation-back.png", "moduledata"}
+	for _, indicator := range game.Indicators {
+		for _, asset := range []string{indicator.Plus, indicator.Minus} {
+			want = append(want, "images/"+asset[strings.LastIndex(asset, "/")+1:])
+		}
+	}
+	sort.Strings(want)
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("module holds %v, want %v", names, want)
+	}
+
+	buildFile, err := module.Open("buildFile.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buildFile.Close()
+	elements, piles, attrs := vassalBuildFile(t, buildFile)
+	wantPiles := map[string]int{"Legislation": 5, "Legislation discard": 0, "Action": 2, "Action discard": 0}
+	if len(piles) != len(wantPiles) {
+		t.Errorf("piles %v, want %v", piles, wantPiles)
+	}
+	for name, count := range wantPiles {
+		if piles[name] != count {
+			t.Errorf("pile %s holds %d cards, want %d", name, piles[name], count)
+		}
+	}
+	if elements["VASSAL.build.module.PlayerHand"] != 3 || elements["entry"] != 3 {
+		t.Errorf("%d hands and %d roster entries, want 3 of each", elements["VASSAL.build.module.PlayerHand"], elements["entry"])
+	}
+	if counters := elements["VASSAL.build.widget.PieceSlot"]; counters != 2*len(game.Indicators) {
+		t.Errorf("%d counters, want %d", counters, 2*len(game.Indicators))
+	}
+	if attrs["name"] != "Sejm & co" || attrs["nextPieceSlotId"] != "22" {
+		t.Errorf("module %q with next piece slot id %s, want Sejm & co and 22", attrs["name"], attrs["nextPieceSlotId"])
+	}
+
+	moduleData, err := module.Open("moduledata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer moduleData.Close()
+	var data struct {
+		Name          string `xml:"name"`
+		VassalVersion string `xml:"VassalVersion"`
+	}
+	if err := xml.NewDecoder(moduleData).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Name != "Sejm & co" || data.VassalVersion != vassalVersion {
+		t.Errorf("moduledata %+v", data)
+	}
+}
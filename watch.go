@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sejm_generator/sejm"
+)
+
+// cardDependencies lists the files whose changes can change a card: the
+// art, the files of the assets it draws, the layout and the game.
+func cardDependencies(card interface{}) []string {
+	deps := []string{cardArt(card)}
+	for _, name := range renderer.CardAssets(card) {
+		deps = append(deps, assetFiles(name)...)
+	}
+	for _, file := range []string{LayoutFile, GameFile} {
+		if file != "" {
+			deps = append(deps, file)
+		}
+	}
+	for i, dep := range deps {
+		deps[i] = filepath.Clean(dep)
+	}
+	return deps
+}
+
+// assetNames returns the asset names of the files in the asset directory.
+func assetNames(paths []string) []string {
+	var names []string
+	if AssetRoot == "" || isAssetPack() {
+		return nil
+	}
+	for _, path := range paths {
+		if rel, err := filepath.Rel(AssetRoot, path); err == nil && filepath.IsLocal(rel) {
+			names = append(names, filepath.ToSlash(rel))
+		}
+	}
+	return names
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot records the modification time and size of the given files and
+// of every file below the given directories. Missing files are left out.
+func snapshot(files []string, dirs []string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[filepath.Clean(file)] = fileStamp{info.ModTime(), info.Size()}
+		}
+	}
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil
+			}
+			if info, err := entry.Info(); err == nil {
+				stamps[filepath.Clean(path)] = fileStamp{info.ModTime(), info.Size()}
+			}
+			return nil
+		})
+	}
+	return stamps
+}
+
+// changedFiles returns the files that were added, removed or modified
+// between two snapshots.
+func changedFiles(before, after map[string]fileStamp) map[string]bool {
+	changed := map[string]bool{}
+	for path, stamp := range after {
+		if old, ok := before[path]; !ok || old != stamp {
+			changed[path] = true
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed[path] = true
+		}
+	}
+	return changed
+}
+
+// watchedCard is a card of a watched deck as it was last rendered.
+type watchedCard struct {
+	line   string // deck line, identifies the card's definition
+	output string
+	deps   []string
+	card   interface{}
+	failed bool
+}
+
+type watcher struct {
+	opts   *options
+	decks  []string
+	cards  map[string]*watchedCard // by deck line
+	stamps map[string]fileStamp
+}
+
+func (w *watcher) watchedFiles() []string {
+	files := append([]string{}, w.decks...)
+	for _, card := range w.cards {
+		files = append(files, card.deps...)
+	}
+	return files
+}
+
+// load reads the decks and returns their cards by deck line.
+func (w *watcher) load() map[string]*watchedCard {
+	cards := map[string]*watchedCard{}
+	for _, path := range w.decks {
+		deck, errs := LoadDeck(path)
+		for _, err := range errs {
+			fmt.Printf("Failed %v\n", err)
+		}
+		for _, card := range deck {
+			line := sejm.NewCardMetadata(card.Card, Version).Line()
+			cards[line] = &watchedCard{line: line, deps: cardDependencies(card.Card), card: card.Card}
+		}
+	}
+	return cards
+}
+
+func (w *watcher) render(card *watchedCard) {
+	output, err := renderCard(card.card, w.opts.OutDir)
+	card.output = output
+	card.failed = err != nil
+	if err != nil {
+		fmt.Printf("Failed %s: %v\n", card.line, err)
+		return
+	}
+	w.opts.printf(normal, "  rendered %s\n", output)
+}
+
+// rebuild re-renders the cards affected by the changed files and reports
+// what it did.
+func (w *watcher) rebuild(changed map[string]bool) {
+	fmt.Printf("%s changed: %s\n", time.Now().Format("15:04:05"), strings.Join(sortedSet(changed), ", "))
+	// The game says how decks are read, so a new one reads them again
+	gameChanged := GameFile != "" && changed[filepath.Clean(GameFile)]
+	if gameChanged {
+		if err := loadGame(); err != nil {
+			fmt.Printf("Failed %v\n", err)
+		}
+	}
+	layoutChanged := LayoutFile != "" && changed[filepath.Clean(LayoutFile)]
+	if layoutChanged {
+		if err := loadLayout(); err != nil {
+			fmt.Printf("Failed %v\n", err)
+		}
+	}
+	switch {
+	case isAssetPack() && changed[filepath.Clean(AssetRoot)]:
+		if err := openAssets(); err != nil {
+			fmt.Printf("Failed %v\n", err)
+		}
+	case gameChanged || layoutChanged:
+		renderer = newRenderer()
+	default:
+		renderer.Forget(assetNames(sortedSet(changed))...)
+	}
+
+	deckChanged := gameChanged
+	for _, deck := range w.decks {
+		deckChanged = deckChanged || changed[filepath.Clean(deck)]
+	}
+
+	cards := w.cards
+	if deckChanged {
+		cards = w.load()
+		for line := range w.cards {
+			if _, ok := cards[line]; !ok {
+				w.opts.printf(normal, "  removed %s\n", line)
+			}
+		}
+	}
+
+	rendered := 0
+	for _, line := range sortedKeysOf(cards) {
+		card := cards[line]
+		old, existed := w.cards[line]
+		switch {
+		case !existed:
+		case old.failed:
+		case touches(card.deps, changed):
+		default:
+			cards[line] = old
+			continue
+		}
+		w.render(card)
+		rendered++
+	}
+	w.cards = cards
+	fmt.Printf("  %d of %d cards rebuilt\n", rendered, len(cards))
+}
+
+func touches(deps []string, changed map[string]bool) bool {
+	for _, dep := range deps {
+		if changed[dep] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedSet(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for item := range set {
+		list = append(list, item)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func sortedKeysOf(cards map[string]*watchedCard) []string {
+	keys := make([]string, 0, len(cards))
+	for key := range cards {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// watchDecks renders the decks, then re-renders the affected cards every
+// time a deck, an art file or an asset changes. It never returns.
+func watchDecks(opts *options, decks []string, interval time.Duration) {
+	w := &watcher{opts: opts, decks: decks}
+	for i, deck := range w.decks {
+		w.decks[i] = filepath.Clean(deck)
+	}
+
+	w.cards = w.load()
+	for _, line := range sortedKeysOf(w.cards) {
+		w.render(w.cards[line])
+	}
+	// New files in the asset directory replace built-in assets
+	var dirs []string
+	watching := append([]string{}, decks...)
+	if AssetRoot != "" {
+		watching = append(watching, AssetRoot)
+		if !isAssetPack() {
+			dirs = append(dirs, AssetRoot)
+		}
+	}
+	w.stamps = snapshot(w.watchedFiles(), dirs)
+	fmt.Printf("Watching %s for changes\n", strings.Join(watching, ", "))
+
+	for {
+		time.Sleep(interval)
+		stamps := snapshot(w.watchedFiles(), dirs)
+		changed := changedFiles(w.stamps, stamps)
+		if len(changed) == 0 {
+			continue
+		}
+
+		w.rebuild(changed)
+		// Cards added by the rebuild may bring new art files to watch
+		for path, stamp := range snapshot(w.watchedFiles(), nil) {
+			if _, ok := stamps[path]; !ok {
+				stamps[path] = stamp
+			}
+		}
+		w.stamps = stamps
+	}
+}